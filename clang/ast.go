@@ -4,56 +4,42 @@ import (
 	"fmt"
 	"modernc.org/cc/v2"
 	"pbg/graph"
-	"reflect"
 )
 
 // Create cc sources from file paths
-func createSources(pbg *graph.ProgramBehaviorGraph) []cc.Source {
+func createSources(pbg *graph.ProgramBehaviorGraph) ([]cc.Source, error) {
 	sources := make([]cc.Source, 0);
 
-	// All directories
-	dirs, err := pbg.Query("g.V().In('contains-file').All()");
+	// Every loaded source file
+	paths, err := pbg.V().Out("has-path").Strings();
 
 	if err != nil {
-		panic(err);
+		return nil, err;
 	}
 
-	fmt.Printf("Directories: %s\n", dirs);
+	fmt.Printf("Found files %s\n", paths);
 
-	for _, dir := range dirs {
-		dir = dir[1:len(dir) - 1]
-
-		// All files from this directory
-		query := fmt.Sprintf("g.V('%s').Out('contains-file').All()", dir)
-
-		files, err := pbg.Query(query);
+	for _, path := range paths {
+		source, err := cc.NewFileSource(path);
 
 		if err != nil {
-			panic(err);
+			return nil, err;
 		}
 
-		fmt.Printf("Found files %s from %s (%s)\n", files, dir, reflect.TypeOf(dir));
-
-		for _, file := range files {
-			file = file[1:len(file) - 1];
-
-			source, err := cc.NewFileSource(dir + "/" + file);
-
-			if err != nil {
-				panic(err);
-			}
-
-			sources = append(sources, source);
-		}
+		sources = append(sources, source);
 	}
 
-	return sources;
+	return sources, nil;
 }
 
 // Create an AST from the file sources
-func createAST(sources []cc.Source, _includePaths []string, _sysIncludePaths []string) *cc.TranslationUnit {
+func createAST(sources []cc.Source, _includePaths []string, _sysIncludePaths []string) (*cc.TranslationUnit, error) {
 	predef, includePaths, sysIncludePaths, err := cc.HostConfig("-std=c99")
 
+	if err != nil {
+		return nil, err
+	}
+
 	sources = append([]cc.Source { cc.NewStringSource("<predef>", predef) }, sources...)
 
 	tu, err := cc.Translate(&cc.Tweaks{
@@ -74,10 +60,10 @@ func createAST(sources []cc.Source, _includePaths []string, _sysIncludePaths []s
 	}, includePaths, sysIncludePaths, sources...);
 
 	if err != nil {
-		panic(fmt.Sprintf("Error: %v", err));
+		return nil, err;
 	}
 
-	return tu;	
+	return tu, nil;	
 }
 
 func walkFuncAST(pbg *graph.ProgramBehaviorGraph, funcDecl *cc.FunctionDefinition) {
@@ -86,7 +72,7 @@ func walkFuncAST(pbg *graph.ProgramBehaviorGraph, funcDecl *cc.FunctionDefinitio
 	fmt.Printf("Found type %v\n", declType)
 }
 
-func walkAST(pbg *graph.ProgramBehaviorGraph, tu *cc.TranslationUnit) {
+func walkAST(pbg *graph.ProgramBehaviorGraph, tu *cc.TranslationUnit) error {
 	list := tu.ExternalDeclarationList
 
 	for list != nil {
@@ -97,52 +83,55 @@ func walkAST(pbg *graph.ProgramBehaviorGraph, tu *cc.TranslationUnit) {
 		} else if funcDecl := extDecl.FunctionDefinition; funcDecl != nil {
 
 		} else {
-			panic(fmt.Sprintf("Unknown decl %v", extDecl))
+			return fmt.Errorf("unknown decl %v", extDecl)
 		}
 
 		list = list.ExternalDeclarationList
 	}
+
+	return nil
 }
 
-func loadElements(pbg *graph.ProgramBehaviorGraph, opt map [string] interface{}) {
-	if _, ok := opt["doWork"]; !ok {
-		return
+func loadElements(pbg *graph.ProgramBehaviorGraph, opt map [string] interface{}) error {
+	if doWork, _ := opt["doWork"].(bool); !doWork {
+		return nil
 	}
 
-	sources := createSources(pbg);
+	sources, err := createSources(pbg);
 
-	if len(sources) == 0 {
-		fmt.Printf("No sources found, skipping ast...\n");
-		return
+	if err != nil {
+		return err
 	}
 
-	_sysIncludePaths, ok := opt["sysIncludePaths"].([]interface {})
-	sysIncludePaths := make([]string, 0)
-
-	for _, path := range _sysIncludePaths {
-		sysIncludePaths = append(sysIncludePaths, path.(string))
+	if len(sources) == 0 {
+		fmt.Printf("No sources found, skipping ast...\n");
+		return nil
 	}
 
-	_includePaths, ok := opt["includePaths"].([]interface {})
-	includePaths := make([]string, 0)
+	sysIncludePaths, _ := opt["sysIncludePaths"].([]string)
+	includePaths, _ := opt["includePaths"].([]string)
 
-	if ok {
-		for _, path := range _includePaths {
-			includePaths = append(includePaths, path.(string))
-		}
-	}
 
+	tu, err := createAST(sources, includePaths, sysIncludePaths);
 
-	tu := createAST(sources, includePaths, sysIncludePaths);
-
-	if tu == nil {
-		fmt.Printf("failed to make ast\n")
-		return
+	if err != nil {
+		return fmt.Errorf("failed to make ast: %v", err)
 	}
 
-	walkAST(pbg, tu)
+	return walkAST(pbg, tu)
 }
 
 func init() {
-	graph.RegisterProvider("clang", loadElements, "files");
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "clang",
+		Run: loadElements,
+		Deps: []string{ "files" },
+		Options: []graph.PBGOption{
+			{ Name: "doWork", Type: graph.OptionBool, Default: false, Description: "parse the loaded sources, still experimental" },
+			{ Name: "sysIncludePaths", Type: graph.OptionStringList, Description: "system include directories" },
+			{ Name: "includePaths", Type: graph.OptionStringList, Description: "include directories" },
+		},
+		Produces: []string{},
+		Consumes: []string{ "has-path" },
+	});
 }
\ No newline at end of file
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	_ "net/http/pprof"
 	"fmt"
 	"os"
-	// "log"
+	"os/signal"
+	"log"
 	_ "pbg/files"
 	_ "pbg/clang"
 	_ "pbg/elf"
@@ -21,6 +23,40 @@ func genUsage() {
 	os.Exit(1)
 }
 
+// Context cancelled by Ctrl-C so a long query can be stopped; a second Ctrl-C
+// exits right away. Calling stop restores the default handling.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	done := make(chan struct{})
+
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		select {
+		case <-signals:
+			log.Printf("Interrupted, press Ctrl-C again to exit immediately\n")
+			cancel()
+		case <-done:
+			return
+		}
+
+		select {
+		case <-signals:
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+
+	return ctx, stop
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		genUsage()
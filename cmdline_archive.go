@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"pbg/graph"
+)
+
+// Write the whole graph to a file, or stdout, in one of the archive formats
+func projExportCmd() {
+	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+	exportDb := exportCmd.String("db", "", "database file path")
+	exportBackend := exportCmd.String("backend", "leveldb", "database backend")
+	exportOut := exportCmd.String("out", "", "file to write to, stdout by default")
+	exportFormat := exportCmd.String("format", "", "nquads, jsonld, graphml or pquads, guessed from -out by default; jsonld is built in memory, so prefer nquads or pquads for large graphs")
+	exportGzip := exportCmd.Bool("gzip", false, "compress the output, implied by a .gz extension")
+
+	exportCmd.Parse(os.Args[3:])
+
+	format, gzipped := graph.ArchiveFormatOf(*exportOut)
+
+	if *exportFormat != "" {
+		format = *exportFormat
+	} else if format == "" {
+		format = "nquads"
+	}
+
+	pbg, err := graph.NewPBG(*exportBackend, *exportDb, false)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	pbg.SetContext(ctx)
+
+	var w io.Writer = os.Stdout
+
+	if *exportOut != "" {
+		file, err := os.Create(*exportOut)
+
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		defer file.Close()
+		w = file
+	}
+
+	buffered := bufio.NewWriter(w)
+	w = buffered
+
+	var compressor *gzip.Writer
+
+	if gzipped || *exportGzip {
+		compressor = gzip.NewWriter(w)
+		w = compressor
+	}
+
+	count, err := pbg.Export(w, format)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			log.Fatalf("%v\n", err)
+		}
+	}
+
+	if err := buffered.Flush(); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	log.Printf("Exported %d quads as %s\n", count, format)
+}
+
+// Load an archive into a database, creating it if asked to
+func projImportCmd() {
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	importDb := importCmd.String("db", "", "database file path")
+	importBackend := importCmd.String("backend", "leveldb", "database backend")
+	importIn := importCmd.String("in", "", "archive to read, stdin by default")
+	importFormat := importCmd.String("format", "", "nquads, jsonld, graphml or pquads, guessed from -in by default")
+	importInit := importCmd.Bool("init", false, "create the database first")
+
+	importCmd.Parse(os.Args[3:])
+
+	format, _ := graph.ArchiveFormatOf(*importIn)
+
+	if *importFormat != "" {
+		format = *importFormat
+	} else if format == "" {
+		format = "nquads"
+	}
+
+	var r io.Reader = os.Stdin
+
+	if *importIn != "" {
+		file, err := os.Open(*importIn)
+
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		defer file.Close()
+		r = file
+	}
+
+	// Compressed archives are recognized by their magic number
+	buffered := bufio.NewReader(r)
+	r = buffered
+
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		decompressor, err := gzip.NewReader(buffered)
+
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		defer decompressor.Close()
+		r = decompressor
+	}
+
+	pbg, err := graph.NewPBG(*importBackend, *importDb, *importInit)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	pbg.SetContext(ctx)
+
+	count, err := pbg.Import(r, format)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	log.Printf("Imported %d quads from %s\n", count, format)
+}
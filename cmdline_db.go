@@ -2,13 +2,14 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"pbg/graph"
 	"flag"
 )
 
 func dbUsage() {
-	fmt.Printf("Usage: %s database [init -db file.db] [add -db file.db -s .. -v .. -o ..] [query -db file.db -cmd ...]\n", os.Args[0])
+	fmt.Printf("Usage: %s database [init -db file.db] [add -db file.db -s .. -v .. -o ..] [query -db file.db -cmd ... -limit n -offset n -format json|jsonl|csv|tsv|nquads -out file -timeout 30s -lang gizmo|mql|graphql(-tags graphql builds only)] [stats -db file.db -format text|json -timeout 30s]\n", os.Args[0])
 	os.Exit(1)
 }
 
@@ -20,7 +21,7 @@ func dbInitCmd() {
 	_, err := graph.NewPBG("leveldb", *initFile, true)
 
 	if err != nil {
-		panic(err)
+		log.Fatalf("%v\n", err)
 	}
 }
 
@@ -35,32 +36,56 @@ func dbAddCmd() {
 	pbg, err := graph.NewPBG("leveldb", *addFile, false)
 
 	if err != nil {
-		panic(err)
+		log.Fatalf("%v\n", err)
 	}
 
 	pbg.AddRelation(*addSubject, *addVerb, *addObject);
+
+	if err := pbg.Err(); err != nil {
+		log.Fatalf("%v\n", err)
+	}
 }
 
 func dbQueryCmd() {
 	queryCmd := flag.NewFlagSet("query", flag.ExitOnError)
 	queryFile := queryCmd.String("db", "", "name of the database")
 	queryString := queryCmd.String("cmd", "", "command to execute")
+	queryLimit := queryCmd.Int("limit", graph.PBG_QUERY_LIMIT, "maximum number of results, -1 for no limit")
+	queryOffset := queryCmd.Int("offset", 0, "number of results to skip")
+	queryFormat := queryCmd.String("format", "", "output format: json, jsonl, csv, tsv or nquads")
+	queryOut := queryCmd.String("out", "", "file to write formatted results to instead of stdout")
+	queryTimeout := queryCmd.Duration("timeout", 0, "stop the query after this long, e.g. 30s")
+	queryLang := queryCmd.String("lang", "gizmo", "query language: gizmo, mql or, when built with -tags graphql, graphql")
 	queryCmd.Parse(os.Args[3:]);
 
 	pbg, err := graph.NewPBG("leveldb", *queryFile, false)
 
 	if err != nil {
-		panic(err)
+		log.Fatalf("%v\n", err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	opts := graph.PBGQueryOptions{ Limit: *queryLimit, Offset: *queryOffset, Context: ctx, Timeout: *queryTimeout, Language: *queryLang }
+
+	if *queryFormat != "" {
+		writeQueryResults(pbg, *queryString, opts, *queryFormat, *queryOut)
+		return
 	}
 
-	results, err := pbg.Query(*queryString)
+	results, page, err := pbg.QueryPage(*queryString, opts)
 
 	if err != nil {
-		panic(err)
+		log.Fatalf("%v\n", err)
 	}
 
 	for i, res := range results {
-		fmt.Printf("%d: %s\n", i, res)
+		fmt.Printf("%d: %s\n", *queryOffset + i, res)
+	}
+
+	if page.Truncated {
+		log.Printf("Results were truncated, continue with -offset=%d or raise -limit\n", page.Next)
 	}
 }
 
@@ -73,6 +98,7 @@ func databaseCmd() {
 	case "init": dbInitCmd()
 	case "add": dbAddCmd()
 	case "query": dbQueryCmd()
+	case "stats": statsCmd("stats")
 	default: dbUsage()
 	}
 }
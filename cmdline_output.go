@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"pbg/graph"
+	"strings"
+)
+
+// Run a query and write its results in a structured format, to a file when
+// one is given and to stdout otherwise
+func writeQueryResults(pbg *graph.ProgramBehaviorGraph, query string, opts graph.PBGQueryOptions, format string, out string) {
+	known := false
+
+	for _, name := range graph.PBGOutputFormats {
+		known = known || name == format
+	}
+
+	if !known {
+		log.Fatalf("Unknown format %s, expected one of %s\n", format, strings.Join(graph.PBGOutputFormats, ", "))
+	}
+
+	rows, page, err := pbg.QueryRows(query, opts)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	file := os.Stdout
+
+	if out != "" {
+		file, err = os.Create(out)
+
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		defer file.Close()
+	}
+
+	writer := bufio.NewWriter(file)
+
+	if err := graph.WriteRows(writer, format, rows); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	if page.Truncated {
+		log.Printf("Results were truncated, continue with -offset=%d or raise -limit\n", page.Next)
+	}
+}
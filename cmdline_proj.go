@@ -1,56 +1,316 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
 	"log"
 	"flag"
+	"pbg/datalog"
 	"pbg/graph"
 	"encoding/json"
 	"os"
 	"io/ioutil"
+	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
 )
 
 func projUsage() {
-	log.Printf("Usage: %s project [create -config=file.json -db=database -backend=backend] [query -db=database -backend=backend -query=file.js -draw=output.png]\n", os.Args[0]);
+	log.Printf("Usage: %s project [create -config=file.json -db=database -backend=backend -run=name -jobs=n -errors=abort|continue -whitelist=a,b -blacklist=c -reuse] [update -config=file.json -db=database -backend=backend -run=name -jobs=n -errors=abort|continue -whitelist=a,b -blacklist=c] [providers] [repl -db=database -backend=backend -limit=n -format=text -param=key=value ... -timeout=30s -lang=gizmo|mql|graphql(-tags graphql builds only)] [serve -db=database -backend=backend -addr=localhost:8081 -timeout=30s] [query -db=database -backend=backend -query=file.js -datalog=dir -predicates=a,b -exclude=c -dl=file.dl -draw=output.dot -draw-format=dot|mermaid|graphml|gexf|html -cluster=function|cu|section -color -collapse-steps=n -max-nodes=n -size=w,h -run=name,... -limit=n -offset=n -format=json|jsonl|csv|tsv|nquads -out=file -param=key=value ... -timeout=30s -lang=gizmo|mql|graphql(-tags graphql builds only)] [draw -db=database -backend=backend -focus=node -depth=n -predicates=a,b -exclude=c -limit=n -addresses -out=file -draw-format=dot|mermaid|graphml|gexf|html -cluster=function|cu|section -color -collapse-steps=n -max-nodes=n -size=w,h -timeout=30s] [runs -db=database -backend=backend -compare=run1,run2 -predicate=pred] [info -db=database -backend=backend -format=text|json -timeout=30s] [import-facts -db=database -backend=backend -dir=dir -predicate-map=rel=pred,... -name=datalog -program=file.dl] [export -db=database -backend=backend -out=file -format=nquads|jsonld|graphml|pquads -gzip] [import -db=database -backend=backend -in=file -format=nquads|jsonld|graphml|pquads -init]\n", os.Args[0]);
 	os.Exit(1);
 }
 
+// Hand the options of a project configuration to the graph. The run section
+// names the execution being recorded, unless overridden on the command line.
+func projConfigure(pbg *graph.ProgramBehaviorGraph, options map[string] map[string] interface{}, run string, jobs int, errors string) {
+	if section, ok := options["run"]; ok {
+		if name, ok := section["name"].(string); ok {
+			pbg.SetRun(name)
+		}
+
+		delete(options, "run")
+	}
+
+	if run != "" {
+		pbg.SetRun(run)
+	}
+
+	pbg.SetWorkers(jobs)
+
+	switch errors {
+	case "abort":
+		pbg.SetErrorPolicy(graph.AbortOnError)
+	case "continue":
+		pbg.SetErrorPolicy(graph.ContinueOnError)
+	default:
+		log.Fatalf("Unknown error policy %s, expected abort or continue\n", errors)
+	}
+
+	for key, val := range options {
+		pbg.SetOptions(key, val)
+	}
+}
+
+// Repeatable -param key=value flag
+type queryParams map[string] string
+
+func (params queryParams) String() string {
+	pairs := make([]string, 0, len(params))
+
+	for key, value := range params {
+		pairs = append(pairs, key + "=" + value)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (params queryParams) Set(pair string) error {
+	parts := strings.SplitN(pair, "=", 2)
+
+	if len(parts) != 2 {
+		return fmt.Errorf("expected key=value, got %s", pair)
+	}
+
+	params[parts[0]] = parts[1]
+	return nil
+}
+
+// Hand -param bindings to the query session
+func projSetParams(pbg *graph.ProgramBehaviorGraph, params queryParams) {
+	for key, value := range params {
+		if err := pbg.SetQueryParam(key, value); err != nil {
+			log.Fatalf("%v\n", err)
+		}
+	}
+}
+
+// Read a project configuration file
+func projReadConfig(path string) map[string] map[string] interface{} {
+	file, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	options := make(map[string] map[string] interface {})
+
+	if err := json.Unmarshal(file, &options); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	return options
+}
+
 func projCreateCmd() {
 	createCmd := flag.NewFlagSet("create", flag.ExitOnError)
 	createFile := createCmd.String("config", "", "configuation file path")
 	createDb := createCmd.String("db", "", "database file path")
 	createBackend := createCmd.String("backend", "leveldb", "database backend")
-	createWhitelist := createCmd.String("whitelist", "", "pass whitelist")
+	createWhitelist := createCmd.String("whitelist", "", "pass whitelist, dependencies are added automatically")
+	createBlacklist := createCmd.String("blacklist", "", "passes to skip along with their dependents")
+	createReuse := createCmd.Bool("reuse", false, "open an existing database and reuse the output of providers that already ran")
+	createRun := createCmd.String("run", "", "name of the run trace providers record into")
+	createJobs := createCmd.Int("jobs", 0, "providers to run concurrently (0 for one per cpu)")
+	createErrors := createCmd.String("errors", "abort", "on provider failure, abort or continue with what doesn't depend on it")
 
 	createCmd.Parse(os.Args[3:])
 
-	file, err := ioutil.ReadFile(*createFile)
+	options := projReadConfig(*createFile)
+
+	pbg, err := graph.NewPBG(*createBackend, *createDb, !*createReuse)
 
 	if err != nil {
-		panic(err);
+		log.Fatalf("%v\n", err)
 	}
 
-	options := make(map[string] map[string] interface {})
+	projConfigure(pbg, options, *createRun, *createJobs, *createErrors)
 
-	if err := json.Unmarshal(file, &options); err != nil {
-		panic(err)
+	filter := graph.PBGProviderFilter{
+		Whitelist: strings.Split(*createWhitelist, ","),
+		Blacklist: strings.Split(*createBlacklist, ","),
+		Reuse: *createReuse,
 	}
 
-	pbg, err := graph.NewPBG(*createBackend, *createDb, true)
+	plan, err := graph.PlanProviders(pbg, filter)
 
 	if err != nil {
-		panic(err)
+		log.Fatalf("Invalid provider setup: %v\n", err)
 	}
 
-	for key, val := range options {
-		pbg.SetOptions(key, val)
+	if err := graph.ValidateOptions(pbg, plan); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	log.Printf("Providers will run in this order:\n")
+
+	for i, provider := range plan {
+		log.Printf("\t%d. %s\n", i + 1, provider)
+	}
+
+	if err := graph.ExecutePlan(pbg, plan); err != nil {
+		log.Fatalf("Failed to execute providers: %v\n", err)
+	}
+}
+
+func projUpdateCmd() {
+	updateCmd := flag.NewFlagSet("update", flag.ExitOnError)
+	updateFile := updateCmd.String("config", "", "configuation file path")
+	updateDb := updateCmd.String("db", "", "database file path")
+	updateBackend := updateCmd.String("backend", "leveldb", "database backend")
+	updateWhitelist := updateCmd.String("whitelist", "", "only consider these passes and their dependencies")
+	updateBlacklist := updateCmd.String("blacklist", "", "passes to leave untouched along with their dependents")
+	updateRun := updateCmd.String("run", "", "name of the run trace providers record into (defaults to the last one)")
+	updateJobs := updateCmd.Int("jobs", 0, "providers to run concurrently (0 for one per cpu)")
+	updateErrors := updateCmd.String("errors", "abort", "on provider failure, abort or continue with what doesn't depend on it")
+
+	updateCmd.Parse(os.Args[3:])
+
+	options := projReadConfig(*updateFile)
+
+	pbg, err := graph.NewPBG(*updateBackend, *updateDb, false)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	projConfigure(pbg, options, *updateRun, *updateJobs, *updateErrors)
+
+	filter := graph.PBGProviderFilter{
+		Whitelist: strings.Split(*updateWhitelist, ","),
+		Blacklist: strings.Split(*updateBlacklist, ","),
+		Reuse: true,
+	}
+
+	if err := graph.UpdateProviders(pbg, filter); err != nil {
+		log.Fatalf("Failed to update providers: %v\n", err)
+	}
+}
+
+// Describe every provider, its options and the predicates it deals with
+func projProvidersCmd() {
+	for _, spec := range graph.ProviderSpecs() {
+		fmt.Printf("%s\n", spec.Name)
+
+		if len(spec.Deps) > 0 {
+			fmt.Printf("  depends on: %s\n", strings.Join(spec.Deps, ", "))
+		}
+
+		if spec.RunScoped {
+			fmt.Printf("  records into a run\n")
+		}
+
+		if spec.Options != nil {
+			fmt.Printf("  options:\n")
+
+			for _, option := range append(append([]graph.PBGOption{}, graph.PBGCommonOptions...), spec.Options...) {
+				extra := ""
+
+				if option.Required {
+					extra = ", required"
+				} else if option.Default != nil {
+					extra = fmt.Sprintf(", default %v", option.Default)
+				}
+
+				fmt.Printf("    %s (%s%s): %s\n", option.Name, option.Type, extra, option.Description)
+			}
+		}
+
+		if len(spec.Produces) > 0 {
+			fmt.Printf("  produces: %s\n", strings.Join(spec.Produces, ", "))
+		}
+
+		if len(spec.Consumes) > 0 {
+			fmt.Printf("  consumes: %s\n", strings.Join(spec.Consumes, ", "))
+		}
+	}
+}
+
+// Register the flags controlling drawings, returning how to read them once
+// parsed
+func drawFlags(cmd *flag.FlagSet) func() graph.PBGDrawOptions {
+	format := cmd.String("draw-format", "", "drawing format: dot, mermaid, graphml, gexf or html, guessed from the file name by default")
+	cluster := cmd.String("cluster", "", "group drawn nodes by function, cu or section")
+	color := cmd.Bool("color", false, "colour drawn edges by predicate family")
+	collapse := cmd.Int("collapse-steps", 0, "draw chains of more next-step edges than this as one edge, 0 to keep them")
+	maxNodes := cmd.Int("max-nodes", 0, "draw at most this many nodes, 0 for no limit")
+	size := cmd.String("size", "7.75,10.25", "graphviz page size in inches, empty for none")
+
+	return func() graph.PBGDrawOptions {
+		return graph.PBGDrawOptions{
+			Format: *format,
+			Cluster: *cluster,
+			Color: *color,
+			CollapseSteps: *collapse,
+			MaxNodes: *maxNodes,
+			Size: *size,
+		}
+	}
+}
+
+// Draw the neighborhood of a node, found through the Go API rather than a
+// query
+func projDrawCmd() {
+	drawCmd := flag.NewFlagSet("draw", flag.ExitOnError)
+	drawDb := drawCmd.String("db", "", "database file path")
+	drawBackend := drawCmd.String("backend", "leveldb", "database backend")
+	drawFocus := drawCmd.String("focus", "", "node to draw around: <pbg:...>, an address or a name such as main")
+	drawDepth := drawCmd.Int("depth", 2, "steps to take away from the focus")
+	drawPredicates := drawCmd.String("predicates", "", "comma separated predicates to follow, all by default")
+	drawExclude := drawCmd.String("exclude", "", "comma separated predicates not to follow")
+	drawLimit := drawCmd.Int("limit", 50, "edges per node and direction, -1 for no limit")
+	drawAddresses := drawCmd.Bool("addresses", true, "follow edges through addresses, such as the instructions accessing a location")
+	drawOut := drawCmd.String("out", "", "file to write the drawing to")
+	drawTimeout := drawCmd.Duration("timeout", 0, "stop after this long, e.g. 30s")
+	drawOpts := drawFlags(drawCmd)
+
+	drawCmd.Parse(os.Args[3:])
+
+	if *drawFocus == "" || *drawOut == "" {
+		projUsage()
+	}
+
+	pbg, err := graph.NewPBG(*drawBackend, *drawDb, false)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	pbg.SetQueryTimeout(*drawTimeout)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	pbg.SetContext(ctx)
+
+	centers, err := pbg.ResolveNodes(*drawFocus)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	opts := graph.PBGNeighborhoodOptions{ Depth: *drawDepth, Limit: *drawLimit, Addresses: *drawAddresses }
+
+	if *drawPredicates != "" {
+		opts.Predicates = strings.Split(*drawPredicates, ",")
+	}
+
+	if *drawExclude != "" {
+		opts.Exclude = strings.Split(*drawExclude, ",")
+	}
+
+	edges, err := pbg.NeighborhoodWith(opts, centers...)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
 	}
 
-	whitelist := strings.Split(*createWhitelist, ",")
-	sort.Strings(whitelist)
+	log.Printf("Drawing %d edges around %d nodes\n", len(edges), len(centers))
 
-	graph.ExecuteProviders(pbg, whitelist)
+	if err := pbg.DrawTriplets(edges, *drawOut, drawOpts()); err != nil {
+		log.Fatalf("%v\n", err)
+	}
 }
 
 func projQueryCmd() {
@@ -58,42 +318,283 @@ func projQueryCmd() {
 	queryDb := queryCmd.String("db", "", "database file path")
 	queryBackend := queryCmd.String("backend", "leveldb", "database backend")
 	queryQuery := queryCmd.String("query", "", "query file path")
-	queryDraw := queryCmd.String("draw", "", "location to write graphviz output")
+	queryDraw := queryCmd.String("draw", "", "location to write a drawing of the subject/predicate/object results to")
+	queryDrawOpts := drawFlags(queryCmd)
 	queryDatalog := queryCmd.String("datalog", "", "location to write datalog output")
+	queryPredicates := queryCmd.String("predicates", "", "comma separated predicates to write with -datalog, all by default")
+	queryExclude := queryCmd.String("exclude", "", "comma separated predicates to leave out of -datalog")
+	queryDl := queryCmd.String("dl", "", "datalog program to evaluate on the graph")
+	queryRun := queryCmd.String("run", "", "comma separated runs whose facts -datalog and -dl read, or the single run the V() paths of a gizmo query are scoped to")
+	queryLimit := queryCmd.Int("limit", graph.PBG_QUERY_LIMIT, "maximum number of results, -1 for no limit")
+	queryOffset := queryCmd.Int("offset", 0, "number of results to skip")
+	queryFormat := queryCmd.String("format", "", "output format: json, jsonl, csv, tsv or nquads")
+	queryOut := queryCmd.String("out", "", "file to write formatted results to instead of stdout, or directory for -dl outputs")
+	queryTimeout := queryCmd.Duration("timeout", 0, "stop the query after this long, e.g. 30s")
+	queryLang := queryCmd.String("lang", "gizmo", "query language: gizmo, mql or, when built with -tags graphql, graphql")
+	queryParam := queryParams{}
+	queryCmd.Var(queryParam, "param", "key=value made available to the query, may be repeated")
 
 	queryCmd.Parse(os.Args[3:])
 
 	pbg, err := graph.NewPBG(*queryBackend, *queryDb, false)
 
 	if err != nil {
-		panic(err)
+		log.Fatalf("%v\n", err)
+	}
+
+	pbg.SetQueryLimit(*queryLimit)
+	pbg.SetQueryTimeout(*queryTimeout)
+	projSetParams(pbg, queryParam)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	pbg.SetContext(ctx)
+
+	runs := make([]string, 0)
+
+	if *queryRun != "" {
+		runs = strings.Split(*queryRun, ",")
 	}
 
 	if *queryDatalog != "" {
-		pbg.GenerateDatalog(*queryDatalog)
+		opts := graph.PBGDatalogOptions{ Runs: runs }
+
+		if *queryPredicates != "" {
+			opts.Predicates = strings.Split(*queryPredicates, ",")
+		}
+
+		if *queryExclude != "" {
+			opts.Exclude = strings.Split(*queryExclude, ",")
+		}
+
+		manifest, err := pbg.GenerateDatalog(*queryDatalog, opts)
+
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		for _, rel := range manifest.Relations {
+			log.Printf("%s: %d rows\n", rel.Relation, rel.Rows)
+		}
+	} else if *queryDl != "" {
+		projDatalogCmd(pbg, *queryDl, runs, *queryOut)
 	} else {
-		queryString, err := ioutil.ReadFile(*queryQuery)
+		queryBytes, err := ioutil.ReadFile(*queryQuery)
 
 		if err != nil {
-			panic(err)
+			log.Fatalf("%v\n", err)
+		}
+
+		queryString := string(queryBytes)
+
+		if *queryLang != "gizmo" && (*queryDraw != "" || len(runs) > 0) {
+			log.Fatalf("-draw and -run need a gizmo query\n")
+		}
+
+		// Gizmo queries start their paths with V() to only see the run
+		if len(runs) > 1 {
+			log.Fatalf("A gizmo query takes a single -run, found %d\n", len(runs))
+		}
+
+		if len(runs) == 1 {
+			if err := pbg.SetQueryRun(runs[0]); err != nil {
+				log.Fatalf("Failed to find run: %v\n", err)
+			}
 		}
 
 		if *queryDraw != "" {
-			pbg.Draw(string(queryString), *queryDraw)
+			if err := pbg.DrawWith(queryString, *queryDraw, queryDrawOpts()); err != nil {
+				log.Fatalf("%v\n", err)
+			}
+		} else if *queryFormat != "" {
+			writeQueryResults(pbg, queryString, graph.PBGQueryOptions{ Offset: *queryOffset, Language: *queryLang }, *queryFormat, *queryOut)
 		} else {
-			results, err := pbg.Query(string(queryString))
+			results, page, err := pbg.QueryPage(queryString, graph.PBGQueryOptions{ Offset: *queryOffset, Language: *queryLang })
 
 			if err != nil {
-				panic(err)
+				log.Fatalf("%v\n", err)
 			}
 
 			for i, res := range results {
-				log.Printf("%d: %s\n", i, res)
+				log.Printf("%d: %s\n", *queryOffset + i, res)
+			}
+
+			if page.Truncated {
+				log.Printf("Results were truncated, continue with -offset=%d or raise -limit\n", page.Next)
+			}
+		}
+	}
+}
+
+// Evaluate a datalog program and print its outputs, or write them to
+// <relation>.csv files in a directory like Souffle does
+func projDatalogCmd(pbg *graph.ProgramBehaviorGraph, path string, runs []string, out string) {
+	src, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	program, err := datalog.Parse(string(src))
+
+	if err != nil {
+		log.Fatalf("%s: %v\n", path, err)
+	}
+
+	db, err := pbg.EvaluateDatalog(program, runs...)
+
+	if err != nil {
+		log.Fatalf("%s: %v\n", path, err)
+	}
+
+	for _, rel := range program.Outputs {
+		var file *os.File
+		writer := bufio.NewWriter(os.Stdout)
+		prefix := rel + "\t"
+
+		if out != "" {
+			file, err = os.Create(filepath.Join(out, rel + ".csv"))
+
+			if err != nil {
+				log.Fatalf("%v\n", err)
+			}
+
+			writer = bufio.NewWriter(file)
+			prefix = ""
+		}
+
+		tuples := db.Tuples(rel)
+
+		for _, tuple := range tuples {
+			columns := make([]string, 0, len(tuple))
+
+			for _, value := range tuple {
+				columns = append(columns, datalog.FormatValue(value))
+			}
+
+			writer.WriteString(prefix + strings.Join(columns, "\t") + "\n")
+		}
+
+		if err := writer.Flush(); err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		// Outputs are closed as they are written, not all at the end
+		if file != nil {
+			if err := file.Close(); err != nil {
+				log.Fatalf("%v\n", err)
 			}
 		}
+
+		log.Printf("%s: %d tuples\n", rel, len(tuples))
+	}
+}
+
+func projRunsCmd() {
+	runsCmd := flag.NewFlagSet("runs", flag.ExitOnError)
+	runsDb := runsCmd.String("db", "", "database file path")
+	runsBackend := runsCmd.String("backend", "leveldb", "database backend")
+	runsCompare := runsCmd.String("compare", "", "two comma separated runs to compare")
+	runsPredicate := runsCmd.String("predicate", "", "predicate to compare between runs")
+
+	runsCmd.Parse(os.Args[3:])
+
+	pbg, err := graph.NewPBG(*runsBackend, *runsDb, false)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	if *runsCompare == "" {
+		runs, err := pbg.Runs()
+
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		for _, run := range runs {
+			fmt.Println(run)
+		}
+
+		return
+	}
+
+	compare := strings.Split(*runsCompare, ",")
+
+	if len(compare) != 2 || *runsPredicate == "" {
+		projUsage()
+	}
+
+	onlyA, onlyB, err := pbg.CompareRuns(compare[0], compare[1], *runsPredicate)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	for _, triplet := range onlyA {
+		fmt.Printf("- %s %s %s\n", quad.StringOf(triplet.Subject()), *runsPredicate, quad.StringOf(triplet.Object()))
+	}
+
+	for _, triplet := range onlyB {
+		fmt.Printf("+ %s %s %s\n", quad.StringOf(triplet.Subject()), *runsPredicate, quad.StringOf(triplet.Object()))
 	}
 }
 
+// Load Datalog results from a directory back into the graph
+func projImportFactsCmd() {
+	importCmd := flag.NewFlagSet("import-facts", flag.ExitOnError)
+	importDb := importCmd.String("db", "", "database file path")
+	importBackend := importCmd.String("backend", "leveldb", "database backend")
+	importDir := importCmd.String("dir", "", "directory of .csv or .facts relations to import")
+	importMap := importCmd.String("predicate-map", "", "comma separated rel=predicate pairs to import, every relation by default")
+	importName := importCmd.String("name", graph.DefaultDerivedName, "name of the label results are stored under, replacing earlier imports")
+	importProgram := importCmd.String("program", "", "datalog program the results came from, whose declarations type their columns")
+
+	importCmd.Parse(os.Args[3:])
+
+	if *importDir == "" {
+		projUsage()
+	}
+
+	opts := graph.PBGFactImportOptions{ Predicates: make(map[string] string), Name: *importName, Program: *importProgram }
+
+	if *importMap != "" {
+		for _, pair := range strings.Split(*importMap, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				log.Fatalf("expected rel=predicate, got %s\n", pair)
+			}
+
+			opts.Predicates[parts[0]] = parts[1]
+		}
+	}
+
+	pbg, err := graph.NewPBG(*importBackend, *importDb, false)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	counts, err := pbg.ImportFacts(*importDir, opts)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	rels := make([]string, 0, len(counts))
+
+	for rel := range counts {
+		rels = append(rels, rel)
+	}
+
+	sort.Strings(rels)
+
+	for _, rel := range rels {
+		log.Printf("%s: %d rows\n", rel, counts[rel])
+	}
+}
 
 func projectCmd() {
 	if len(os.Args) < 3 {
@@ -104,8 +605,28 @@ func projectCmd() {
 	switch os.Args[2] {
 	case "create":
 		projCreateCmd();
+	case "update":
+		projUpdateCmd()
+	case "providers":
+		projProvidersCmd()
+	case "repl":
+		projReplCmd()
+	case "serve":
+		projServeCmd()
 	case "query":
 		projQueryCmd()
+	case "runs":
+		projRunsCmd()
+	case "draw":
+		projDrawCmd()
+	case "info":
+		statsCmd("info")
+	case "import-facts":
+		projImportFactsCmd()
+	case "export":
+		projExportCmd()
+	case "import":
+		projImportCmd()
 	default:
 		projUsage();
 	}
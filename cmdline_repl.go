@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"pbg/graph"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const replHelp = `Enter Gizmo statements, e.g. g.V().Has("has-name", "main").All(), or
+MQL queries, or GraphQL ones in builds tagged graphql, after switching with
+.lang.
+Statements spanning several lines are read until their brackets balance.
+Helpers such as resolveTypeName, declLine and sourceAtPC are predefined.
+Ctrl-C stops a running query.
+
+  .load file.js     evaluate a query file
+  .limit n          results per query, -1 for no limit
+  .format name      text, json, jsonl, csv, tsv or nquads
+  .timeout d        stop queries after a duration such as 30s, 0 for none
+  .lang name        gizmo, mql, or graphql in builds tagged graphql
+  .history          list previous statements
+  !n, !!            run statement n again, or the last one
+  .help             show this message
+  .exit             leave the repl
+`
+
+// State of an interactive session
+type repl struct {
+	pbg *graph.ProgramBehaviorGraph
+	limit int
+	timeout time.Duration
+	lang string
+	format string
+	history []string
+	historyFile string
+}
+
+// Net nesting of brackets in a piece of JavaScript, skipping strings and
+// comments. Positive means the statement continues on the next line.
+func bracketDepth(code string) int {
+	depth := 0
+	var quote rune
+
+	runes := []rune(code)
+
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		if quote != 0 {
+			if c == '\\' {
+				i += 1
+			} else if c == quote {
+				quote = 0
+			}
+
+			continue
+		}
+
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '/':
+			// Line comments run to the end of their line
+			if i + 1 < len(runes) && runes[i + 1] == '/' {
+				for i < len(runes) && runes[i] != '\n' {
+					i += 1
+				}
+			}
+		case '(', '{', '[':
+			depth += 1
+		case ')', '}', ']':
+			depth -= 1
+		}
+	}
+
+	return depth
+}
+
+// Load history left by previous sessions
+func (r *repl) loadHistory() {
+	data, err := ioutil.ReadFile(r.historyFile)
+
+	if err != nil {
+		return
+	}
+
+	for _, entry := range strings.Split(string(data), "\x00") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			r.history = append(r.history, entry)
+		}
+	}
+}
+
+// Remember a statement for this and later sessions
+func (r *repl) remember(statement string) {
+	r.history = append(r.history, statement)
+
+	if r.historyFile == "" {
+		return
+	}
+
+	file, err := os.OpenFile(r.historyFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+
+	if err != nil {
+		return
+	}
+
+	defer file.Close()
+
+	// Statements can span lines, so entries are NUL separated
+	file.WriteString(statement + "\x00")
+}
+
+// Print results as aligned tag: value lines
+func printRows(rows []graph.PBGQueryRow) {
+	for i, row := range rows {
+		tags := make([]string, 0, len(row.Tags))
+
+		for tag := range row.Tags {
+			tags = append(tags, tag)
+		}
+
+		sort.Strings(tags)
+
+		fmt.Printf("%d:\n", i)
+
+		for _, tag := range tags {
+			fmt.Printf("  %-12s %s\n", tag + ":", row.Tags[tag])
+		}
+
+		if row.Value != nil {
+			fmt.Printf("  %-12s %v\n", graph.ValueColumn + ":", row.Value)
+		}
+	}
+}
+
+// Evaluate a statement and show its results with how long it took
+func (r *repl) evaluate(statement string) {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	start := time.Now()
+	rows, page, err := r.pbg.QueryRows(statement, graph.PBGQueryOptions{ Limit: r.limit, Context: ctx, Timeout: r.timeout, Language: r.lang })
+	elapsed := time.Now().Sub(start)
+
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return
+	}
+
+	if r.format == "text" {
+		printRows(rows)
+	} else {
+		writer := bufio.NewWriter(os.Stdout)
+
+		if err := graph.WriteRows(writer, r.format, rows); err != nil {
+			fmt.Printf("error: %v\n", err)
+		}
+
+		writer.Flush()
+	}
+
+	more := ""
+
+	if page.Truncated {
+		more = ", truncated"
+	}
+
+	fmt.Printf("(%d results in %s%s)\n", len(rows), elapsed.String(), more)
+}
+
+// Handle a dot command, returning false once the session should end
+func (r *repl) command(line string) bool {
+	fields := strings.Fields(line)
+
+	switch fields[0] {
+	case ".exit", ".quit":
+		return false
+	case ".help":
+		fmt.Print(replHelp)
+	case ".history":
+		for i, statement := range r.history {
+			fmt.Printf("%4d  %s\n", i + 1, statement)
+		}
+	case ".load":
+		if len(fields) != 2 {
+			fmt.Println("usage: .load file.js")
+			break
+		}
+
+		data, err := ioutil.ReadFile(fields[1])
+
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			break
+		}
+
+		r.evaluate(string(data))
+	case ".limit":
+		limit, err := strconv.Atoi(strings.Join(fields[1:], ""))
+
+		if err != nil {
+			fmt.Println("usage: .limit n")
+			break
+		}
+
+		r.limit = limit
+	case ".timeout":
+		timeout, err := time.ParseDuration(strings.Join(fields[1:], ""))
+
+		if err != nil {
+			fmt.Println("usage: .timeout duration")
+			break
+		}
+
+		r.timeout = timeout
+	case ".lang":
+		if len(fields) != 2 {
+			fmt.Println("usage: .lang " + strings.Join(graph.PBGQueryLanguages, "|"))
+			break
+		}
+
+		known := false
+
+		for _, lang := range graph.PBGQueryLanguages {
+			known = known || lang == fields[1]
+		}
+
+		if !known {
+			fmt.Printf("unknown language %s\n", fields[1])
+			break
+		}
+
+		r.lang = fields[1]
+	case ".format":
+		if len(fields) != 2 {
+			fmt.Println("usage: .format text|" + strings.Join(graph.PBGOutputFormats, "|"))
+			break
+		}
+
+		known := fields[1] == "text"
+
+		for _, format := range graph.PBGOutputFormats {
+			known = known || format == fields[1]
+		}
+
+		if !known {
+			fmt.Printf("unknown format %s\n", fields[1])
+			break
+		}
+
+		r.format = fields[1]
+	default:
+		fmt.Printf("unknown command %s, try .help\n", fields[0])
+	}
+
+	return true
+}
+
+// Pick a statement from history for !n and !!
+func (r *repl) recall(line string) (string, bool) {
+	if len(r.history) == 0 {
+		return "", false
+	}
+
+	if line == "!!" {
+		return r.history[len(r.history) - 1], true
+	}
+
+	index, err := strconv.Atoi(line[1:])
+
+	if err != nil || index < 1 || index > len(r.history) {
+		return "", false
+	}
+
+	return r.history[index - 1], true
+}
+
+func (r *repl) run() {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64 * 1024), 16 * 1024 * 1024)
+
+	pending := ""
+
+	for {
+		if pending == "" {
+			fmt.Print("pbg> ")
+		} else {
+			fmt.Print("...> ")
+		}
+
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		line := scanner.Text()
+
+		if pending == "" {
+			trimmed := strings.TrimSpace(line)
+
+			if trimmed == "" {
+				continue
+			}
+
+			if strings.HasPrefix(trimmed, ".") {
+				if !r.command(trimmed) {
+					return
+				}
+
+				continue
+			}
+
+			if strings.HasPrefix(trimmed, "!") {
+				statement, ok := r.recall(trimmed)
+
+				if !ok {
+					fmt.Println("no such history entry")
+					continue
+				}
+
+				fmt.Println(statement)
+				r.evaluate(statement)
+				continue
+			}
+		}
+
+		pending += line + "\n"
+
+		if bracketDepth(pending) > 0 {
+			continue
+		}
+
+		statement := strings.TrimSpace(pending)
+		pending = ""
+
+		r.remember(statement)
+		r.evaluate(statement)
+	}
+}
+
+func projReplCmd() {
+	replCmd := flag.NewFlagSet("repl", flag.ExitOnError)
+	replDb := replCmd.String("db", "", "database file path")
+	replBackend := replCmd.String("backend", "leveldb", "database backend")
+	replLimit := replCmd.Int("limit", graph.PBG_QUERY_LIMIT, "results per query, -1 for no limit")
+	replFormat := replCmd.String("format", "text", "output format: text, json, jsonl, csv, tsv or nquads")
+	replTimeout := replCmd.Duration("timeout", 0, "stop queries after this long, e.g. 30s")
+	replLang := replCmd.String("lang", "gizmo", "query language: gizmo, mql or, when built with -tags graphql, graphql")
+	replParam := queryParams{}
+	replCmd.Var(replParam, "param", "key=value made available to queries, may be repeated")
+
+	replCmd.Parse(os.Args[3:])
+
+	pbg, err := graph.NewPBG(*replBackend, *replDb, false)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	projSetParams(pbg, replParam)
+
+	r := &repl{ pbg: pbg, limit: *replLimit, timeout: *replTimeout, lang: *replLang, format: *replFormat }
+
+	if home, err := os.UserHomeDir(); err == nil {
+		r.historyFile = filepath.Join(home, ".pbg_history")
+		r.loadHistory()
+	}
+
+	fmt.Print("Type .help for commands\n")
+	r.run()
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"pbg/graph"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Edges shown per node and direction unless a request asks otherwise
+const serveEdgeLimit = 100
+
+// Deepest neighborhood a request may ask for, each step can multiply the
+// edges walked by the limit
+const serveMaxDepth = 4
+
+// Serves the query API and explorer page for one database
+type server struct {
+	pbg *graph.ProgramBehaviorGraph
+	timeout time.Duration
+
+	// Predicates in the database, listed once when the server starts
+	predicates []string
+}
+
+type serveQuery struct {
+	Query string `json:"query"`
+	Limit int `json:"limit"`
+	Offset int `json:"offset"`
+	Lang string `json:"lang"`
+}
+
+func serveJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Failed to write response: %v\n", err)
+	}
+}
+
+func serveError(w http.ResponseWriter, status int, err error) {
+	serveJSON(w, status, map[string] string { "error": err.Error() })
+}
+
+// Integer request parameter, or def when it's absent
+func intParam(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("%s should be an integer, got %s", name, value)
+	}
+
+	return n, nil
+}
+
+// Run a query, posted as {"query": ..., "limit": n, "offset": n, "lang": ...}
+// or passed as the q, limit, offset and lang parameters of a GET. The
+// language defaults to Gizmo.
+func (s *server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	var req serveQuery
+	var err error
+
+	switch r.Method {
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+
+		if err == nil {
+			err = json.Unmarshal(body, &req)
+		}
+
+		if err != nil {
+			serveError(w, http.StatusBadRequest, err)
+			return
+		}
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("q")
+		req.Lang = r.URL.Query().Get("lang")
+
+		if req.Limit, err = intParam(r, "limit", 0); err == nil {
+			req.Offset, err = intParam(r, "offset", 0)
+		}
+
+		if err != nil {
+			serveError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		serveError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	if req.Query == "" {
+		serveError(w, http.StatusBadRequest, fmt.Errorf("missing query"))
+		return
+	}
+
+	start := time.Now()
+
+	// Every request gets sessions of its own so clients don't see each
+	// other's globals, and stops when its client goes away
+	rows, page, err := s.pbg.QueryView().QueryRows(req.Query, graph.PBGQueryOptions{
+		Limit: req.Limit,
+		Offset: req.Offset,
+		Context: r.Context(),
+		Timeout: s.timeout,
+		Language: req.Lang,
+	})
+
+	if err != nil {
+		serveError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if rows == nil {
+		rows = []graph.PBGQueryRow{}
+	}
+
+	serveJSON(w, http.StatusOK, map[string] interface{} {
+		"results": rows,
+		"truncated": page.Truncated,
+		"next": page.Next,
+		"elapsed": time.Now().Sub(start).String(),
+	})
+}
+
+// Walks of the graph stop when their client goes away or the server's
+// timeout passes
+func (s *server) queryOptions(r *http.Request) graph.PBGQueryOptions {
+	return graph.PBGQueryOptions{ Context: r.Context(), Timeout: s.timeout }
+}
+
+// Edges in and out of the node given by the id parameter
+func (s *server) handleNode(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	limit, err := intParam(r, "limit", serveEdgeLimit)
+
+	if err == nil && id == "" {
+		err = fmt.Errorf("missing id")
+	} else if err == nil && limit < 0 {
+		err = fmt.Errorf("limit can't be negative")
+	}
+
+	if err != nil {
+		serveError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	node := graph.ParseValue(id)
+	out, in, truncated, err := s.pbg.Neighbors(node, limit, s.queryOptions(r))
+
+	if err != nil {
+		serveError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	serveJSON(w, http.StatusOK, map[string] interface{} {
+		"node": graph.FormatValue(node),
+		"out": out,
+		"in": in,
+		"truncated": truncated,
+	})
+}
+
+// Edges around the node given by the id parameter, for drawing
+func (s *server) handleNeighborhood(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	depth, err := intParam(r, "depth", 1)
+
+	var limit int
+
+	if err == nil {
+		limit, err = intParam(r, "limit", 25)
+	}
+
+	if err == nil && id == "" {
+		err = fmt.Errorf("missing id")
+	} else if err == nil && (depth < 0 || depth > serveMaxDepth) {
+		err = fmt.Errorf("depth should be between 0 and %d", serveMaxDepth)
+	} else if err == nil && limit < 0 {
+		err = fmt.Errorf("limit can't be negative")
+	}
+
+	if err != nil {
+		serveError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	node := graph.ParseValue(id)
+	edges, err := s.pbg.NeighborhoodWith(graph.PBGNeighborhoodOptions{
+		Depth: depth,
+		Limit: limit,
+		Query: s.queryOptions(r),
+	}, node)
+
+	if err != nil {
+		serveError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	serveJSON(w, http.StatusOK, map[string] interface{} {
+		"node": graph.FormatValue(node),
+		"edges": edges,
+	})
+}
+
+func (s *server) handlePredicates(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, http.StatusOK, s.predicates)
+}
+
+func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Only the languages this build supports are offered
+	options := ""
+
+	for _, lang := range graph.PBGQueryLanguages {
+		options += "<option>" + lang + "</option>"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, strings.Replace(explorerPage, "{{languages}}", options, 1))
+}
+
+func projServeCmd() {
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	serveDb := serveCmd.String("db", "", "database file path")
+	serveBackend := serveCmd.String("backend", "leveldb", "database backend")
+	serveAddr := serveCmd.String("addr", "localhost:8081", "address to listen on")
+	serveTimeout := serveCmd.Duration("timeout", 30 * time.Second, "longest a query may run, 0 for no limit")
+
+	serveCmd.Parse(os.Args[3:])
+
+	pbg, err := graph.NewPBG(*serveBackend, *serveDb, false)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	// Listing predicates scans every quad, too slow to do per page load
+	predicates, err := pbg.Predicates()
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	s := &server{ pbg: pbg, timeout: *serveTimeout, predicates: predicates }
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/api/query", s.handleQuery)
+	mux.HandleFunc("/api/node", s.handleNode)
+	mux.HandleFunc("/api/neighborhood", s.handleNeighborhood)
+	mux.HandleFunc("/api/predicates", s.handlePredicates)
+
+	log.Printf("Serving %s on http://%s/\n", *serveDb, *serveAddr)
+
+	if err := http.ListenAndServe(*serveAddr, mux); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+}
+
+// Explorer served at /, self contained so it works without network access
+const explorerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pbg explorer</title>
+<style>
+body { font-family: sans-serif; margin: 0; display: flex; height: 100vh; }
+#side { width: 45%; overflow: auto; padding: 12px; border-right: 1px solid #ccc; box-sizing: border-box; }
+#main { flex: 1; display: flex; flex-direction: column; }
+#graph { flex: 1; }
+input[type=text], textarea { width: 100%; box-sizing: border-box; font-family: monospace; }
+table { border-collapse: collapse; width: 100%; font-family: monospace; font-size: 12px; }
+td, th { border-bottom: 1px solid #eee; padding: 2px 4px; text-align: left; vertical-align: top; word-break: break-all; }
+a { color: #0645ad; cursor: pointer; }
+.error { color: #b00; }
+.edge { stroke: #999; }
+.center circle { fill: #d62728; }
+circle { fill: #1f77b4; cursor: pointer; }
+text { font-size: 10px; font-family: monospace; }
+</style>
+</head>
+<body>
+<div id="side">
+  <h3>Node</h3>
+  <form id="nodeForm">
+    <input type="text" id="nodeId" placeholder="&lt;pbg:...&gt;, &quot;main&quot; or 4198400">
+    depth <input type="number" id="depth" value="1" min="1" max="4" style="width: 4em">
+    <button>Explore</button>
+  </form>
+  <div id="node"></div>
+  <h3>Query</h3>
+  <form id="queryForm">
+    <textarea id="query" rows="5">g.V().Has("has-name", "main").All()</textarea>
+    <select id="lang">{{languages}}</select>
+    limit <input type="number" id="limit" value="100" style="width: 6em">
+    <button>Run</button>
+  </form>
+  <div id="results"></div>
+  <h3>Predicates</h3>
+  <div id="predicates"></div>
+</div>
+<div id="main"><svg id="graph"></svg></div>
+<script>
+function el(tag, attrs, text) {
+  var ns = "http://www.w3.org/2000/svg";
+  var e = ["svg", "g", "circle", "line", "text", "title"].indexOf(tag) >= 0 ? document.createElementNS(ns, tag) : document.createElement(tag);
+  for (var k in attrs || {}) e.setAttribute(k, attrs[k]);
+  if (text !== undefined) e.textContent = text;
+  return e;
+}
+
+function api(path, options) {
+  return fetch(path, options).then(function (r) {
+    return r.json().then(function (body) {
+      if (!r.ok) throw new Error(body.error);
+      return body;
+    });
+  });
+}
+
+function showError(target, err) {
+  target.innerHTML = "";
+  target.appendChild(el("p", { "class": "error" }, err.message));
+}
+
+// Values that are entities can be explored further
+function nodeLink(value) {
+  if (value.charAt(0) !== "<") return document.createTextNode(value);
+  var a = el("a", {}, value);
+  a.onclick = function () { explore(value); };
+  return a;
+}
+
+function edgeTable(title, edges, columns) {
+  var table = el("table");
+  var head = el("tr");
+  columns.forEach(function (c) { head.appendChild(el("th", {}, c)); });
+  table.appendChild(head);
+  edges.forEach(function (edge) {
+    var row = el("tr");
+    columns.forEach(function (c) {
+      var td = el("td");
+      td.appendChild(nodeLink(edge[c]));
+      row.appendChild(td);
+    });
+    table.appendChild(row);
+  });
+  var div = el("div");
+  div.appendChild(el("h4", {}, title + " (" + edges.length + ")"));
+  div.appendChild(table);
+  return div;
+}
+
+function explore(id) {
+  document.getElementById("nodeId").value = id;
+  var target = document.getElementById("node");
+  var q = "id=" + encodeURIComponent(id);
+  api("/api/node?" + q).then(function (body) {
+    target.innerHTML = "";
+    target.appendChild(edgeTable("Out", body.out, ["predicate", "object"]));
+    target.appendChild(edgeTable("In", body["in"], ["subject", "predicate"]));
+    if (body.truncated) target.appendChild(el("p", {}, "Only the first edges are shown."));
+  }).catch(function (err) { showError(target, err); });
+  var depth = document.getElementById("depth").value;
+  api("/api/neighborhood?" + q + "&depth=" + depth).then(function (body) {
+    draw(body.node, body.edges);
+  }).catch(function (err) { showError(target, err); });
+}
+
+// Lay the neighborhood out with a few rounds of a simple force simulation
+function draw(center, edges) {
+  var svg = document.getElementById("graph");
+  svg.innerHTML = "";
+  var width = svg.clientWidth, height = svg.clientHeight;
+  var nodes = {}, list = [];
+  function node(id) {
+    if (!nodes[id]) {
+      var angle = list.length * 2.4;
+      nodes[id] = { id: id, x: width / 2 + Math.cos(angle) * 40 * Math.sqrt(list.length), y: height / 2 + Math.sin(angle) * 40 * Math.sqrt(list.length) };
+      list.push(nodes[id]);
+    }
+    return nodes[id];
+  }
+  node(center);
+  var links = edges.map(function (e) { return { s: node(e.subject), t: node(e.object), p: e.predicate }; });
+  for (var round = 0; round < 200; round++) {
+    list.forEach(function (a) {
+      a.dx = (width / 2 - a.x) * 0.01; a.dy = (height / 2 - a.y) * 0.01;
+      list.forEach(function (b) {
+        if (a === b) return;
+        var dx = a.x - b.x, dy = a.y - b.y, d2 = dx * dx + dy * dy + 0.01;
+        a.dx += dx * 500 / d2; a.dy += dy * 500 / d2;
+      });
+    });
+    links.forEach(function (l) {
+      var dx = l.t.x - l.s.x, dy = l.t.y - l.s.y;
+      l.s.dx += dx * 0.02; l.s.dy += dy * 0.02;
+      l.t.dx -= dx * 0.02; l.t.dy -= dy * 0.02;
+    });
+    list.forEach(function (a) {
+      a.x = Math.max(20, Math.min(width - 20, a.x + a.dx));
+      a.y = Math.max(20, Math.min(height - 20, a.y + a.dy));
+    });
+  }
+  links.forEach(function (l) {
+    svg.appendChild(el("line", { "class": "edge", x1: l.s.x, y1: l.s.y, x2: l.t.x, y2: l.t.y }));
+    svg.appendChild(el("text", { x: (l.s.x + l.t.x) / 2, y: (l.s.y + l.t.y) / 2, fill: "#888" }, l.p));
+  });
+  list.forEach(function (n) {
+    var g = el("g", { "class": n.id === center ? "center" : "" });
+    var circle = el("circle", { cx: n.x, cy: n.y, r: 6 });
+    circle.appendChild(el("title", {}, n.id));
+    circle.onclick = function () { if (n.id.charAt(0) === "<") explore(n.id); };
+    g.appendChild(circle);
+    g.appendChild(el("text", { x: n.x + 8, y: n.y + 3 }, n.id.length > 40 ? n.id.slice(0, 40) + "..." : n.id));
+    svg.appendChild(g);
+  });
+}
+
+function runQuery() {
+  var target = document.getElementById("results");
+  var body = {
+    query: document.getElementById("query").value,
+    lang: document.getElementById("lang").value,
+    limit: parseInt(document.getElementById("limit").value, 10) || 0
+  };
+  api("/api/query", { method: "POST", body: JSON.stringify(body) }).then(function (body) {
+    target.innerHTML = "";
+    var columns = [];
+    body.results.forEach(function (row) {
+      for (var k in row) if (columns.indexOf(k) < 0) columns.push(k);
+    });
+    var table = el("table");
+    var head = el("tr");
+    columns.forEach(function (c) { head.appendChild(el("th", {}, c)); });
+    table.appendChild(head);
+    body.results.forEach(function (row) {
+      var tr = el("tr");
+      columns.forEach(function (c) {
+        var td = el("td");
+        var v = row[c];
+        if (typeof v === "string" && v.indexOf("pbg:") === 0) td.appendChild(nodeLink("<" + v + ">"));
+        else if (v !== undefined) td.textContent = typeof v === "object" ? JSON.stringify(v) : String(v);
+        tr.appendChild(td);
+      });
+      table.appendChild(tr);
+    });
+    target.appendChild(el("p", {}, body.results.length + " results in " + body.elapsed + (body.truncated ? ", truncated" : "")));
+    target.appendChild(table);
+  }).catch(function (err) { showError(target, err); });
+}
+
+document.getElementById("nodeForm").onsubmit = function (e) { e.preventDefault(); explore(document.getElementById("nodeId").value); };
+document.getElementById("queryForm").onsubmit = function (e) { e.preventDefault(); runQuery(); };
+
+api("/api/predicates").then(function (predicates) {
+  var target = document.getElementById("predicates");
+  target.textContent = predicates.join(", ");
+}).catch(function (err) { showError(document.getElementById("predicates"), err); });
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"pbg/graph"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Print what a database holds, as text or JSON
+func statsCmd(name string) {
+	statsCmd := flag.NewFlagSet(name, flag.ExitOnError)
+	statsDb := statsCmd.String("db", "", "database file path")
+	statsBackend := statsCmd.String("backend", "leveldb", "database backend")
+	statsFormat := statsCmd.String("format", "text", "output format: text or json")
+	statsTimeout := statsCmd.Duration("timeout", 0, "stop counting after this long, e.g. 30s")
+
+	statsCmd.Parse(os.Args[3:])
+
+	if *statsFormat != "text" && *statsFormat != "json" {
+		log.Fatalf("Unknown format %s, expected text or json\n", *statsFormat)
+	}
+
+	pbg, err := graph.NewPBG(*statsBackend, *statsDb, false)
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	pbg.SetQueryTimeout(*statsTimeout)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	pbg.SetContext(ctx)
+
+	stats, err := pbg.Stats()
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	// Not every backend keeps its data at a path we can measure
+	size, sizeErr := graph.DatabaseSize(*statsDb)
+
+	if sizeErr != nil {
+		log.Printf("Unable to measure %s: %v\n", *statsDb, sizeErr)
+		size = -1
+	}
+
+	if *statsFormat == "json" {
+		report := struct {
+			*graph.PBGStats
+			Backend string `json:"backend"`
+			Size int64 `json:"size"`
+		}{ stats, *statsBackend, size }
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		return
+	}
+
+	printStats(stats, *statsBackend, size)
+}
+
+// Counts of a map in a stable order, e.g. entity=3, string=2
+func formatCounts(counts map[string] int) string {
+	keys := make([]string, 0, len(counts))
+
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", key, counts[key]))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func printStats(stats *graph.PBGStats, backend string, size int64) {
+	if size >= 0 {
+		fmt.Printf("backend: %s, %d bytes on disk\n", backend, size)
+	} else {
+		fmt.Printf("backend: %s\n", backend)
+	}
+
+	fmt.Printf("quads: %d\n", stats.Quads)
+	fmt.Printf("nodes: %d (%s)\n", stats.Nodes, formatCounts(stats.NodeKinds))
+
+	fmt.Printf("\npredicates:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  predicate\tedges\tsubjects\tobjects\tobject kinds\n")
+
+	for _, p := range stats.Predicates {
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%d\t%s\n", p.Predicate, p.Edges, p.Subjects, p.Objects, formatCounts(p.ObjectKinds))
+	}
+
+	w.Flush()
+
+	fmt.Printf("\nlabels:\n")
+	labels := make([]string, 0, len(stats.Labels))
+
+	for label := range stats.Labels {
+		labels = append(labels, label)
+	}
+
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		name := label
+
+		if name == "" {
+			name = "(none)"
+		}
+
+		fmt.Printf("  %s: %d quads\n", name, stats.Labels[label])
+	}
+
+	fmt.Printf("\nruns: %s\n", strings.Join(stats.Runs, ", "))
+
+	fmt.Printf("\nproviders:\n")
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  provider\trun\tfinished\tduration\tfingerprint\n")
+
+	for _, p := range stats.Providers {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", p.Name, p.Run, p.Finished, p.Duration, p.Fingerprint)
+	}
+
+	w.Flush()
+}
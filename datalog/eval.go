@@ -0,0 +1,930 @@
+package datalog
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values are int64 for number columns and string for symbol columns
+type Value interface{}
+
+// Tuples of one relation, with hash indexes over the column combinations
+// lookups have used so far
+type relation struct {
+	arity int
+	tuples [][]Value
+	set map[string] bool
+	indexes map[uint64] map[string] []int
+}
+
+func newRelation(arity int) *relation {
+	return &relation{
+		arity: arity,
+		set: make(map[string] bool),
+		indexes: make(map[uint64] map[string] []int),
+	}
+}
+
+func writeKey(key *strings.Builder, v Value) {
+	switch v := v.(type) {
+	case int64:
+		key.WriteByte('i')
+		key.WriteString(strconv.FormatInt(v, 10))
+	case string:
+		key.WriteByte('s')
+		key.WriteString(strconv.Itoa(len(v)))
+		key.WriteByte(':')
+		key.WriteString(v)
+	}
+
+	key.WriteByte(';')
+}
+
+// Key of the columns of a tuple selected by a mask
+func maskKey(tuple []Value, mask uint64) string {
+	var key strings.Builder
+
+	for i, v := range tuple {
+		if mask & (1 << uint(i)) != 0 {
+			writeKey(&key, v)
+		}
+	}
+
+	return key.String()
+}
+
+// Add a tuple, returning false if it was already there
+func (r *relation) add(tuple []Value) bool {
+	full := uint64(1 << uint(r.arity)) - 1
+	key := maskKey(tuple, full)
+
+	if r.set[key] {
+		return false
+	}
+
+	r.set[key] = true
+	r.tuples = append(r.tuples, tuple)
+
+	for mask, index := range r.indexes {
+		sub := maskKey(tuple, mask)
+		index[sub] = append(index[sub], len(r.tuples) - 1)
+	}
+
+	return true
+}
+
+// Positions of the tuples whose masked columns match the key
+func (r *relation) lookup(mask uint64, key string) []int {
+	index, ok := r.indexes[mask]
+
+	if !ok {
+		index = make(map[string] []int)
+
+		for i, tuple := range r.tuples {
+			sub := maskKey(tuple, mask)
+			index[sub] = append(index[sub], i)
+		}
+
+		r.indexes[mask] = index
+	}
+
+	return index[key]
+}
+
+// How an argument of an atom is matched against a tuple
+const (
+	argConst = iota
+	argBound
+	argBind
+	argCheck
+	argIgnore
+)
+
+type argPlan struct {
+	kind int
+	slot int
+	value Value
+}
+
+const (
+	stepScan = iota
+	stepNegation
+	stepCompare
+	stepAssign
+)
+
+type step struct {
+	kind int
+	rel string
+	delta bool
+	args []argPlan
+	mask uint64
+
+	// Comparisons and assignments
+	op string
+	left term
+	right term
+	slot int
+}
+
+// A rule compiled for evaluation, optionally reading one body atom from the
+// tuples added in the previous round
+type plan struct {
+	rule *rule
+	steps []step
+	slots map[string] int
+}
+
+func termVars(t term, vars map[string] bool) {
+	switch t := t.(type) {
+	case variable:
+		vars[t.name] = true
+	case binary:
+		termVars(t.left, vars)
+		termVars(t.right, vars)
+	}
+}
+
+func allBound(t term, bound map[string] bool) bool {
+	vars := make(map[string] bool)
+	termVars(t, vars)
+
+	for name := range vars {
+		if !bound[name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Order the body so every atom is scanned with as many columns bound as
+// possible and filters run as soon as their variables are known
+func compile(r *rule, delta int) (*plan, error) {
+	p := &plan{ rule: r, slots: make(map[string] int) }
+	bound := make(map[string] bool)
+	placed := make([]bool, len(r.body))
+
+	slot := func(name string) int {
+		if s, ok := p.slots[name]; ok {
+			return s
+		}
+
+		p.slots[name] = len(p.slots)
+		return p.slots[name]
+	}
+
+	scan := func(i int) {
+		a := r.body[i].(*atom)
+		s := step{ kind: stepScan, rel: a.rel, delta: i == delta }
+		local := make(map[string] bool)
+
+		for col, arg := range a.args {
+			switch arg := arg.(type) {
+			case constant:
+				s.args = append(s.args, argPlan{ kind: argConst, value: arg.value })
+				s.mask |= 1 << uint(col)
+			case wildcard:
+				s.args = append(s.args, argPlan{ kind: argIgnore })
+			case variable:
+				switch {
+				case bound[arg.name]:
+					s.args = append(s.args, argPlan{ kind: argBound, slot: slot(arg.name) })
+					s.mask |= 1 << uint(col)
+				case local[arg.name]:
+					// Repeated within the atom, compared once the first is bound
+					s.args = append(s.args, argPlan{ kind: argCheck, slot: slot(arg.name) })
+				default:
+					s.args = append(s.args, argPlan{ kind: argBind, slot: slot(arg.name) })
+					local[arg.name] = true
+				}
+			}
+		}
+
+		for name := range local {
+			bound[name] = true
+		}
+
+		p.steps = append(p.steps, s)
+		placed[i] = true
+	}
+
+	// Place every filter and assignment whose inputs are known
+	filters := func() {
+		for progress := true; progress; {
+			progress = false
+
+			for i, literal := range r.body {
+				if placed[i] {
+					continue
+				}
+
+				switch literal := literal.(type) {
+				case *atom:
+					if !literal.negated {
+						continue
+					}
+
+					ready := true
+
+					for _, arg := range literal.args {
+						if v, ok := arg.(variable); ok && !bound[v.name] {
+							ready = false
+						}
+					}
+
+					if !ready {
+						continue
+					}
+
+					s := step{ kind: stepNegation, rel: literal.rel }
+
+					for col, arg := range literal.args {
+						switch arg := arg.(type) {
+						case constant:
+							s.args = append(s.args, argPlan{ kind: argConst, value: arg.value })
+							s.mask |= 1 << uint(col)
+						case variable:
+							s.args = append(s.args, argPlan{ kind: argBound, slot: slot(arg.name) })
+							s.mask |= 1 << uint(col)
+						default:
+							s.args = append(s.args, argPlan{ kind: argIgnore })
+						}
+					}
+
+					p.steps = append(p.steps, s)
+				case *comparison:
+					if allBound(literal.left, bound) && allBound(literal.right, bound) {
+						p.steps = append(p.steps, step{ kind: stepCompare, op: literal.op, left: literal.left, right: literal.right })
+					} else if literal.op != "=" {
+						continue
+					} else if v, ok := literal.left.(variable); ok && !bound[v.name] && allBound(literal.right, bound) {
+						p.steps = append(p.steps, step{ kind: stepAssign, slot: slot(v.name), right: literal.right })
+						bound[v.name] = true
+					} else if v, ok := literal.right.(variable); ok && !bound[v.name] && allBound(literal.left, bound) {
+						p.steps = append(p.steps, step{ kind: stepAssign, slot: slot(v.name), right: literal.left })
+						bound[v.name] = true
+					} else {
+						continue
+					}
+				}
+
+				placed[i] = true
+				progress = true
+			}
+		}
+	}
+
+	if delta >= 0 {
+		scan(delta)
+	}
+
+	for {
+		filters()
+
+		best := -1
+		bestScore := -1
+
+		for i, literal := range r.body {
+			a, ok := literal.(*atom)
+
+			if placed[i] || !ok || a.negated {
+				continue
+			}
+
+			score := 0
+
+			for _, arg := range a.args {
+				switch arg := arg.(type) {
+				case constant:
+					score += 1
+				case variable:
+					if bound[arg.name] {
+						score += 1
+					}
+				}
+			}
+
+			if score > bestScore {
+				best = i
+				bestScore = score
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		scan(best)
+	}
+
+	for i, literal := range r.body {
+		if !placed[i] {
+			return nil, fmt.Errorf("line %d: %s has variables not bound by any atom of the rule", r.line, describeLiteral(literal))
+		}
+	}
+
+	for _, arg := range r.head.args {
+		if !allBound(arg, bound) {
+			return nil, fmt.Errorf("line %d: head of %s has variables not bound by its body", r.line, r.head.rel)
+		}
+	}
+
+	for _, arg := range r.head.args {
+		vars := make(map[string] bool)
+		termVars(arg, vars)
+
+		for name := range vars {
+			slot(name)
+		}
+	}
+
+	return p, nil
+}
+
+func describeLiteral(literal interface{}) string {
+	switch literal := literal.(type) {
+	case *atom:
+		if literal.negated {
+			return "!" + literal.rel
+		}
+
+		return literal.rel
+	case *comparison:
+		return "comparison " + literal.op
+	}
+
+	return "literal"
+}
+
+func evalTerm(t term, env []Value, slots map[string] int) (Value, error) {
+	switch t := t.(type) {
+	case constant:
+		return t.value, nil
+	case variable:
+		return env[slots[t.name]], nil
+	case binary:
+		left, err := evalTerm(t.left, env, slots)
+
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := evalTerm(t.right, env, slots)
+
+		if err != nil {
+			return nil, err
+		}
+
+		a, aok := left.(int64)
+		b, bok := right.(int64)
+
+		if !aok || !bok {
+			return nil, fmt.Errorf("arithmetic on symbols %v %s %v", left, t.op, right)
+		}
+
+		switch t.op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/", "%":
+			if b == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+
+			if t.op == "/" {
+				return a / b, nil
+			}
+
+			return a % b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("can't evaluate %v", t)
+}
+
+func compare(op string, left Value, right Value) (bool, error) {
+	if op == "=" {
+		return left == right, nil
+	}
+
+	if op == "!=" {
+		return left != right, nil
+	}
+
+	cmp := 0
+
+	switch a := left.(type) {
+	case int64:
+		b, ok := right.(int64)
+
+		if !ok {
+			return false, fmt.Errorf("can't compare %v with %v", left, right)
+		}
+
+		if a < b {
+			cmp = -1
+		} else if a > b {
+			cmp = 1
+		}
+	case string:
+		b, ok := right.(string)
+
+		if !ok {
+			return false, fmt.Errorf("can't compare %v with %v", left, right)
+		}
+
+		cmp = strings.Compare(a, b)
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+
+	return cmp >= 0, nil
+}
+
+// Relations of a program, filled with input facts and then evaluated
+type Database struct {
+	program *Program
+	relations map[string] *relation
+}
+
+func NewDatabase(program *Program) *Database {
+	db := &Database{ program: program, relations: make(map[string] *relation) }
+
+	for name, decl := range program.Decls {
+		db.relations[name] = newRelation(len(decl.Columns))
+	}
+
+	return db
+}
+
+// Add an input fact. Values must match the declared column types.
+func (db *Database) Add(rel string, tuple []Value) error {
+	decl, ok := db.program.Decls[rel]
+
+	if !ok {
+		return fmt.Errorf("relation %s isn't declared", rel)
+	}
+
+	if len(tuple) != len(decl.Columns) {
+		return fmt.Errorf("%s has %d columns, got %d values", rel, len(decl.Columns), len(tuple))
+	}
+
+	for i, column := range decl.Columns {
+		_, number := tuple[i].(int64)
+		_, symbol := tuple[i].(string)
+
+		if (column.Type == NumberColumn && !number) || (column.Type == SymbolColumn && !symbol) {
+			return fmt.Errorf("column %s of %s is a %s, got %v", column.Name, rel, column.Type, tuple[i])
+		}
+	}
+
+	db.relations[rel].add(tuple)
+	return nil
+}
+
+// Evaluation state of one stratum
+type evaluator struct {
+	ctx context.Context
+	db *Database
+	delta map[string] *relation
+	emitted int
+}
+
+// Run a plan, calling emit with the variable bindings of every match
+func (e *evaluator) run(p *plan, i int, env []Value, emit func([]Value) error) error {
+	if i == len(p.steps) {
+		e.emitted += 1
+
+		// Checking on every match would be too slow
+		if e.emitted % 4096 == 0 {
+			if err := e.ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		return emit(env)
+	}
+
+	s := &p.steps[i]
+
+	switch s.kind {
+	case stepScan:
+		rel := e.db.relations[s.rel]
+
+		if s.delta {
+			rel = e.delta[s.rel]
+		}
+
+		key := make([]Value, len(s.args))
+
+		for col, arg := range s.args {
+			switch arg.kind {
+			case argConst:
+				key[col] = arg.value
+			case argBound:
+				key[col] = env[arg.slot]
+			}
+		}
+
+		match := func(tuple []Value) error {
+			ok := true
+
+			for col, arg := range s.args {
+				switch arg.kind {
+				case argConst, argBound:
+					ok = tuple[col] == key[col]
+				case argBind:
+					env[arg.slot] = tuple[col]
+				case argCheck:
+					ok = tuple[col] == env[arg.slot]
+				}
+
+				if !ok {
+					break
+				}
+			}
+
+			var err error
+
+			if ok {
+				err = e.run(p, i + 1, env, emit)
+			}
+
+			for _, arg := range s.args {
+				if arg.kind == argBind {
+					env[arg.slot] = nil
+				}
+			}
+
+			return err
+		}
+
+		if s.mask == 0 {
+			count := len(rel.tuples)
+
+			for t := 0; t < count; t++ {
+				if err := match(rel.tuples[t]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		for _, t := range rel.lookup(s.mask, maskKey(key, s.mask)) {
+			if err := match(rel.tuples[t]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case stepNegation:
+		rel := e.db.relations[s.rel]
+		key := make([]Value, len(s.args))
+
+		for col, arg := range s.args {
+			switch arg.kind {
+			case argConst:
+				key[col] = arg.value
+			case argBound:
+				key[col] = env[arg.slot]
+			}
+		}
+
+		if len(rel.lookup(s.mask, maskKey(key, s.mask))) > 0 {
+			return nil
+		}
+
+		return e.run(p, i + 1, env, emit)
+	case stepCompare:
+		left, err := evalTerm(s.left, env, p.slots)
+
+		if err != nil {
+			return fmt.Errorf("line %d: %v", p.rule.line, err)
+		}
+
+		right, err := evalTerm(s.right, env, p.slots)
+
+		if err != nil {
+			return fmt.Errorf("line %d: %v", p.rule.line, err)
+		}
+
+		ok, err := compare(s.op, left, right)
+
+		if err != nil {
+			return fmt.Errorf("line %d: %v", p.rule.line, err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		return e.run(p, i + 1, env, emit)
+	case stepAssign:
+		value, err := evalTerm(s.right, env, p.slots)
+
+		if err != nil {
+			return fmt.Errorf("line %d: %v", p.rule.line, err)
+		}
+
+		env[s.slot] = value
+		err = e.run(p, i + 1, env, emit)
+		env[s.slot] = nil
+
+		return err
+	}
+
+	return nil
+}
+
+// Evaluate a plan, adding the head tuples it derives. New tuples of
+// relations in the stratum are collected for the next round.
+func (e *evaluator) apply(p *plan, next map[string] *relation) error {
+	head := p.rule.head
+	target := e.db.relations[head.rel]
+	env := make([]Value, len(p.slots))
+
+	return e.run(p, 0, env, func(env []Value) error {
+		tuple := make([]Value, len(head.args))
+
+		for i, arg := range head.args {
+			value, err := evalTerm(arg, env, p.slots)
+
+			if err != nil {
+				return fmt.Errorf("line %d: %v", p.rule.line, err)
+			}
+
+			tuple[i] = value
+		}
+
+		if target.add(tuple) && next != nil {
+			if _, ok := next[head.rel]; ok {
+				next[head.rel].add(tuple)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Group relations into strata, each a set of mutually recursive relations
+// listed after everything it depends on. Negation within a stratum can't be
+// evaluated and is reported.
+func (program *Program) strata() ([][]string, error) {
+	deps := make(map[string] []string)
+
+	for _, r := range program.rules {
+		for _, literal := range r.body {
+			if a, ok := literal.(*atom); ok {
+				deps[r.head.rel] = append(deps[r.head.rel], a.rel)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(program.Decls))
+
+	for name := range program.Decls {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	// Tarjan's algorithm yields components after the ones they reach
+	index := make(map[string] int)
+	low := make(map[string] int)
+	onStack := make(map[string] bool)
+	stack := make([]string, 0)
+	strata := make([][]string, 0)
+	component := make(map[string] int)
+
+	var visit func(name string)
+
+	visit = func(name string) {
+		index[name] = len(index)
+		low[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, dep := range deps[name] {
+			if _, seen := index[dep]; !seen {
+				visit(dep)
+
+				if low[dep] < low[name] {
+					low[name] = low[dep]
+				}
+			} else if onStack[dep] && index[dep] < low[name] {
+				low[name] = index[dep]
+			}
+		}
+
+		if low[name] == index[name] {
+			members := make([]string, 0)
+
+			for {
+				top := stack[len(stack) - 1]
+				stack = stack[:len(stack) - 1]
+				onStack[top] = false
+				component[top] = len(strata)
+				members = append(members, top)
+
+				if top == name {
+					break
+				}
+			}
+
+			strata = append(strata, members)
+		}
+	}
+
+	for _, name := range names {
+		if _, seen := index[name]; !seen {
+			visit(name)
+		}
+	}
+
+	for _, r := range program.rules {
+		for _, literal := range r.body {
+			if a, ok := literal.(*atom); ok && a.negated && component[a.rel] == component[r.head.rel] {
+				return nil, fmt.Errorf("line %d: %s negates %s, which depends on it, so the program can't be stratified", r.line, r.head.rel, a.rel)
+			}
+		}
+	}
+
+	return strata, nil
+}
+
+// Derive every relation of the program from its facts, one stratum at a
+// time, using semi-naive evaluation within each
+func (db *Database) Evaluate(ctx context.Context) error {
+	for _, fact := range db.program.facts {
+		tuple := make([]Value, len(fact.args))
+
+		for i, arg := range fact.args {
+			tuple[i] = arg.(constant).value
+		}
+
+		if err := db.Add(fact.rel, tuple); err != nil {
+			return fmt.Errorf("line %d: %v", fact.line, err)
+		}
+	}
+
+	strata, err := db.program.strata()
+
+	if err != nil {
+		return err
+	}
+
+	for _, stratum := range strata {
+		if err := db.evaluateStratum(ctx, stratum); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *Database) evaluateStratum(ctx context.Context, stratum []string) error {
+	members := make(map[string] bool)
+
+	for _, name := range stratum {
+		members[name] = true
+	}
+
+	rules := make([]*rule, 0)
+
+	for _, r := range db.program.rules {
+		if members[r.head.rel] {
+			rules = append(rules, r)
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	e := &evaluator{ ctx: ctx, db: db }
+
+	newDeltas := func() map[string] *relation {
+		deltas := make(map[string] *relation)
+
+		for name := range members {
+			deltas[name] = newRelation(db.relations[name].arity)
+		}
+
+		return deltas
+	}
+
+	// The first round reads every relation in full
+	next := newDeltas()
+
+	for name := range members {
+		for _, tuple := range db.relations[name].tuples {
+			next[name].add(tuple)
+		}
+	}
+
+	recursive := make(map[*rule] []*plan)
+
+	for _, r := range rules {
+		p, err := compile(r, -1)
+
+		if err != nil {
+			return err
+		}
+
+		if err := e.apply(p, next); err != nil {
+			return err
+		}
+
+		// Later rounds join each recursive atom against the new tuples only
+		for i, literal := range r.body {
+			if a, ok := literal.(*atom); ok && !a.negated && members[a.rel] {
+				p, err := compile(r, i)
+
+				if err != nil {
+					return err
+				}
+
+				recursive[r] = append(recursive[r], p)
+			}
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		empty := true
+
+		for _, delta := range next {
+			empty = empty && len(delta.tuples) == 0
+		}
+
+		if empty {
+			return nil
+		}
+
+		e.delta = next
+		next = newDeltas()
+
+		for _, r := range rules {
+			for _, p := range recursive[r] {
+				if err := e.apply(p, next); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Tuples of a relation, sorted
+func (db *Database) Tuples(rel string) [][]Value {
+	r, ok := db.relations[rel]
+
+	if !ok {
+		return nil
+	}
+
+	tuples := append([][]Value{}, r.tuples...)
+
+	sort.Slice(tuples, func(i, j int) bool {
+		for col := range tuples[i] {
+			a, b := tuples[i][col], tuples[j][col]
+
+			if a == b {
+				continue
+			}
+
+			less, _ := compare("<", a, b)
+			return less
+		}
+
+		return false
+	})
+
+	return tuples
+}
+
+// Text of a value as written to output files
+func FormatValue(v Value) string {
+	if n, ok := v.(int64); ok {
+		return strconv.FormatInt(n, 10)
+	}
+
+	return EscapeSymbol(fmt.Sprint(v))
+}
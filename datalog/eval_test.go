@@ -0,0 +1,181 @@
+package datalog
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Parse a program from the queries directory, or a source string
+func parseFixture(t *testing.T, name string) *Program {
+	src, err := ioutil.ReadFile("../queries/" + name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parseSource(t, string(src))
+}
+
+func parseSource(t *testing.T, src string) *Program {
+	program, err := Parse(src)
+
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	return program
+}
+
+// Add facts given per relation and evaluate the program
+func evaluate(t *testing.T, program *Program, facts map[string] [][]Value) *Database {
+	db := NewDatabase(program)
+
+	for rel, tuples := range facts {
+		for _, tuple := range tuples {
+			if err := db.Add(rel, tuple); err != nil {
+				t.Fatalf("add %s%v: %v", rel, tuple, err)
+			}
+		}
+	}
+
+	if err := db.Evaluate(context.Background()); err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+
+	return db
+}
+
+func expectTuples(t *testing.T, db *Database, rel string, expected [][]Value) {
+	got := db.Tuples(rel)
+
+	if len(got) == 0 && len(expected) == 0 {
+		return
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("%s: got %v, expected %v", rel, got, expected)
+	}
+}
+
+func TestMemTest(t *testing.T) {
+	program := parseFixture(t, "mem-test.dl")
+
+	// 16 bytes are allocated at s1 and written past their end at s3, which
+	// also shrinks them with a realloc nothing writes into. s4 frees memory
+	// that was never allocated.
+	db := evaluate(t, program, map[string] [][]Value{
+		"malloc_amt": { { "s1", int64(16) } },
+		"malloc_ptr": { { "s1", int64(1000) } },
+		"next_step": { { "s1", "s2" }, { "s2", "s3" }, { "s3", "s4" } },
+		"step_address": { { "s2", int64(400) }, { "s3", int64(404) }, { "s4", int64(408) } },
+		"write_address": { { int64(400), int64(1008) }, { int64(404), int64(1020) } },
+		"realloc_amt": { { "s3", int64(8) } },
+		"realloc_old_ptr": { { "s3", int64(1000) } },
+		"realloc_new_ptr": { { "s3", int64(3000) } },
+		"free": { { "s4", int64(2000) } },
+	})
+
+	expectTuples(t, db, "malloc_reach", [][]Value{
+		{ "s1", "s2", int64(1000), int64(16) },
+		{ "s1", "s3", int64(1000), int64(16) },
+	})
+	expectTuples(t, db, "invalid_heap_access", [][]Value{ { int64(404), int64(1020), "write" } })
+	expectTuples(t, db, "invalid_free", [][]Value{ { int64(408), int64(2000) } })
+	expectTuples(t, db, "useless_realloc", [][]Value{ { int64(404) } })
+}
+
+func TestDeadWrite(t *testing.T) {
+	program := parseFixture(t, "dead-write.dl")
+
+	// a and c both write x, and b reads it in between
+	db := evaluate(t, program, map[string] [][]Value{
+		"next_address": { { "a", "b" }, { "b", "c" }, { "c", "d" } },
+		"write_address": { { "a", "x" }, { "c", "x" } },
+		"read_address": { { "b", "x" } },
+	})
+
+	expectTuples(t, db, "reachable", [][]Value{
+		{ "a", "b" }, { "a", "c" }, { "a", "d" },
+		{ "b", "c" }, { "b", "d" },
+		{ "c", "d" },
+	})
+	expectTuples(t, db, "not_dead_write", [][]Value{ { "a", "c" } })
+	expectTuples(t, db, "dead_write", [][]Value{ { "a", "a" }, { "c", "a" }, { "c", "c" } })
+}
+
+func TestNegationInCycle(t *testing.T) {
+	for _, src := range []string{
+		`.decl p(x: number)
+		.decl q(x: number)
+		q(1).
+		p(x) :- q(x), !p(x).`,
+
+		`.decl p(x: number)
+		.decl q(x: number)
+		.decl r(x: number)
+		q(1).
+		p(x) :- q(x), !r(x).
+		r(x) :- p(x).`,
+	} {
+		db := NewDatabase(parseSource(t, src))
+		err := db.Evaluate(context.Background())
+
+		if err == nil || !strings.Contains(err.Error(), "can't be stratified") {
+			t.Errorf("expected a stratification error, got %v", err)
+		}
+	}
+}
+
+func TestMismatchedTypes(t *testing.T) {
+	prelude := `.decl n(x: number)
+	.decl s(x: symbol)
+	.decl o(x: number)
+	n(1).
+	s("a").
+	`
+
+	for _, test := range []struct {
+		rule string
+		error string
+	}{
+		{ `o(y) :- s(x), y = x + 1.`, "arithmetic on symbols" },
+		{ `o(x) :- n(x), s(y), x < y.`, "can't compare" },
+		{ `o(x) :- n(x), s(y), y >= x.`, "can't compare" },
+	} {
+		db := NewDatabase(parseSource(t, prelude + test.rule))
+		err := db.Evaluate(context.Background())
+
+		if err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%s: expected %q, got %v", test.rule, test.error, err)
+		}
+	}
+}
+
+func TestAddChecksTypes(t *testing.T) {
+	db := NewDatabase(parseSource(t, `.decl n(x: number, y: symbol)`))
+
+	if err := db.Add("n", []Value{ "1", "a" }); err == nil {
+		t.Errorf("symbol accepted in a number column")
+	}
+
+	if err := db.Add("n", []Value{ int64(1), int64(2) }); err == nil {
+		t.Errorf("number accepted in a symbol column")
+	}
+
+	if err := db.Add("n", []Value{ int64(1), "a" }); err != nil {
+		t.Errorf("valid tuple rejected: %v", err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	db := evaluate(t, parseSource(t, `.decl n(x: number)
+	.decl o(x: number, y: number)
+	n(7).
+	n(1).
+	o(x, y) :- n(x), y = (x + 3) * 2 % 5, x > y.`), nil)
+
+	expectTuples(t, db, "o", [][]Value{ { int64(7), int64(0) } })
+}
@@ -0,0 +1,48 @@
+package datalog
+
+import (
+	"strings"
+)
+
+var symbolEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// Spell a symbol for a tab separated fact file. Backslashes, tabs and line
+// breaks are escaped with a backslash so every fact stays on its own line.
+func EscapeSymbol(s string) string {
+	return symbolEscaper.Replace(s)
+}
+
+// Read back a symbol written by EscapeSymbol. Unknown escapes are kept as
+// they are.
+func UnescapeSymbol(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i + 1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i + 1] {
+		case '\\':
+			b.WriteByte('\\')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i += 1
+	}
+
+	return b.String()
+}
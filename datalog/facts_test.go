@@ -0,0 +1,52 @@
+package datalog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscapeRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"plain",
+		"tab\there",
+		"line\nbreak",
+		"crlf\r\n",
+		"back\\slash",
+		"\\t is not a tab",
+		"trailing\\",
+		"\\\\\t\n",
+	} {
+		escaped := EscapeSymbol(s)
+
+		if strings.ContainsAny(escaped, "\t\n\r") {
+			t.Errorf("%q escaped to %q, which still breaks a fact file", s, escaped)
+		}
+
+		if back := UnescapeSymbol(escaped); back != s {
+			t.Errorf("%q escaped to %q and read back as %q", s, escaped, back)
+		}
+	}
+}
+
+func TestUnescapeUnknown(t *testing.T) {
+	for text, expected := range map[string] string{
+		"\\q": "\\q",
+		"end\\": "end\\",
+		"a\\tb": "a\tb",
+	} {
+		if got := UnescapeSymbol(text); got != expected {
+			t.Errorf("%q read back as %q, expected %q", text, got, expected)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	if got := FormatValue(int64(-16)); got != "-16" {
+		t.Errorf("number formatted as %q", got)
+	}
+
+	if got := FormatValue("a\tb"); got != "a\\tb" {
+		t.Errorf("symbol formatted as %q", got)
+	}
+}
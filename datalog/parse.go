@@ -0,0 +1,855 @@
+package datalog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind of value a relation column holds
+type ColumnType int
+
+const (
+	NumberColumn ColumnType = iota
+	SymbolColumn
+)
+
+func (t ColumnType) String() string {
+	if t == NumberColumn {
+		return "number"
+	}
+
+	return "symbol"
+}
+
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// A relation declared with .decl
+type Decl struct {
+	Name string
+	Columns []Column
+}
+
+// A parsed Datalog program in the subset of Souffle's syntax we use: .type,
+// .decl, .input and .output directives, facts, and rules whose bodies combine
+// atoms, negated atoms and arithmetic comparisons with "," and ";".
+type Program struct {
+	Decls map[string] *Decl
+	Inputs []string
+	Outputs []string
+
+	rules []*rule
+	facts []*atom
+}
+
+// Terms are variables, wildcards, constants and arithmetic over them
+type term interface{}
+
+type variable struct {
+	name string
+}
+
+type wildcard struct{}
+
+type constant struct {
+	value Value
+}
+
+type binary struct {
+	op string
+	left term
+	right term
+}
+
+type atom struct {
+	rel string
+	args []term
+	negated bool
+	line int
+}
+
+type comparison struct {
+	op string
+	left term
+	right term
+	line int
+}
+
+// Rules are kept in disjunctive normal form, one per alternative of the body
+type rule struct {
+	head *atom
+	body []interface{}
+	line int
+}
+
+const (
+	tokEOF = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind int
+	text string
+	line int
+}
+
+// Punctuation, longest first so ":-" wins over ":"
+var punctuation = []string{ ":-", "<:", "!=", "<=", ">=", "(", ")", ",", ";", ".", ":", "!", "<", ">", "=", "+", "-", "*", "/", "%", "|" }
+
+func lex(src string) ([]token, error) {
+	tokens := make([]token, 0)
+	line := 1
+	i := 0
+
+	for i < len(src) {
+		c := src[i]
+
+		switch {
+		case c == '\n':
+			line += 1
+			i += 1
+		case c == ' ' || c == '\t' || c == '\r':
+			i += 1
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i += 1
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i + 2:], "*/")
+
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+
+			line += strings.Count(src[i:i + end + 4], "\n")
+			i += end + 4
+		case c == '"':
+			j := i + 1
+
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j += 1
+				}
+
+				j += 1
+			}
+
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+
+			text, err := strconv.Unquote(src[i:j + 1])
+
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", line, src[i:j + 1])
+			}
+
+			tokens = append(tokens, token{ tokString, text, line })
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+
+			for j < len(src) && (isIdentChar(src[j])) {
+				j += 1
+			}
+
+			tokens = append(tokens, token{ tokNumber, src[i:j], line })
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+
+			for j < len(src) && isIdentChar(src[j]) {
+				j += 1
+			}
+
+			tokens = append(tokens, token{ tokIdent, src[i:j], line })
+			i = j
+		default:
+			found := ""
+
+			for _, p := range punctuation {
+				if strings.HasPrefix(src[i:], p) {
+					found = p
+					break
+				}
+			}
+
+			if found == "" {
+				return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+			}
+
+			tokens = append(tokens, token{ tokPunct, found, line })
+			i += len(found)
+		}
+	}
+
+	return append(tokens, token{ tokEOF, "", line }), nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Decimal or 0x prefixed hex, the latter allowed to fill all 64 bits
+func parseNumber(text string) (int64, error) {
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		n, err := strconv.ParseUint(text[2:], 16, 64)
+		return int64(n), err
+	}
+
+	return strconv.ParseInt(text, 10, 64)
+}
+
+type parser struct {
+	tokens []token
+	pos int
+	types map[string] ColumnType
+	program *Program
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+
+	if tok.kind != tokEOF {
+		p.pos += 1
+	}
+
+	return tok
+}
+
+func (p *parser) is(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokPunct && tok.text == text
+}
+
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %s, found %s", text, describe(p.peek()))
+	}
+
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	tok := p.next()
+
+	if tok.kind != tokIdent {
+		return "", fmt.Errorf("line %d: expected a name, found %s", tok.line, describe(tok))
+	}
+
+	return tok.text, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.peek().line, fmt.Sprintf(format, args...))
+}
+
+func describe(tok token) string {
+	switch tok.kind {
+	case tokEOF:
+		return "end of file"
+	case tokString:
+		return strconv.Quote(tok.text)
+	}
+
+	return tok.text
+}
+
+// Parse a program and check that every relation it uses is declared with the
+// right number of columns
+func Parse(src string) (*Program, error) {
+	tokens, err := lex(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens: tokens,
+		types: map[string] ColumnType { "number": NumberColumn, "unsigned": NumberColumn, "symbol": SymbolColumn },
+		program: &Program{ Decls: make(map[string] *Decl) },
+	}
+
+	for p.peek().kind != tokEOF {
+		if p.accept(".") {
+			err = p.directive()
+		} else {
+			err = p.clause()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.program.check(); err != nil {
+		return nil, err
+	}
+
+	return p.program, nil
+}
+
+func (p *parser) columnType(name string) (ColumnType, error) {
+	t, ok := p.types[name]
+
+	if !ok {
+		return 0, p.errorf("unknown type %s", name)
+	}
+
+	return t, nil
+}
+
+func (p *parser) directive() error {
+	name, err := p.ident()
+
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "type":
+		typeName, err := p.ident()
+
+		if err != nil {
+			return err
+		}
+
+		if !p.accept("=") && !p.accept("<:") {
+			return p.errorf("expected = or <: in the declaration of type %s", typeName)
+		}
+
+		// Unions take the kind of their first member
+		base, err := p.ident()
+
+		if err != nil {
+			return err
+		}
+
+		for p.accept("|") {
+			if _, err := p.ident(); err != nil {
+				return err
+			}
+		}
+
+		t, err := p.columnType(base)
+
+		if err != nil {
+			return err
+		}
+
+		p.types[typeName] = t
+	case "number_type", "symbol_type":
+		typeName, err := p.ident()
+
+		if err != nil {
+			return err
+		}
+
+		p.types[typeName] = NumberColumn
+
+		if name == "symbol_type" {
+			p.types[typeName] = SymbolColumn
+		}
+	case "decl":
+		return p.decl()
+	case "input", "output":
+		for {
+			rel, err := p.ident()
+
+			if err != nil {
+				return err
+			}
+
+			if name == "input" {
+				p.program.Inputs = append(p.program.Inputs, rel)
+			} else {
+				p.program.Outputs = append(p.program.Outputs, rel)
+			}
+
+			// IO parameters only matter to Souffle
+			if p.is("(") {
+				if err := p.skipGroup(); err != nil {
+					return err
+				}
+			}
+
+			if !p.accept(",") {
+				return nil
+			}
+		}
+	default:
+		return p.errorf("unsupported directive .%s", name)
+	}
+
+	return nil
+}
+
+func (p *parser) skipGroup() error {
+	depth := 0
+
+	for {
+		tok := p.next()
+
+		switch {
+		case tok.kind == tokEOF:
+			return fmt.Errorf("line %d: unbalanced parentheses", tok.line)
+		case tok.kind == tokPunct && tok.text == "(":
+			depth += 1
+		case tok.kind == tokPunct && tok.text == ")":
+			depth -= 1
+
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// Representation hints Souffle accepts after a declaration
+var declQualifiers = map[string] bool { "brie": true, "btree": true, "eqrel": true, "inline": true, "magic": true, "no_magic": true }
+
+func (p *parser) decl() error {
+	name, err := p.ident()
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := p.program.Decls[name]; ok {
+		return p.errorf("relation %s declared twice", name)
+	}
+
+	decl := &Decl{ Name: name }
+
+	if err := p.expect("("); err != nil {
+		return err
+	}
+
+	for !p.accept(")") {
+		if len(decl.Columns) > 0 {
+			if err := p.expect(","); err != nil {
+				return err
+			}
+		}
+
+		column, err := p.ident()
+
+		if err != nil {
+			return err
+		}
+
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+
+		typeName, err := p.ident()
+
+		if err != nil {
+			return err
+		}
+
+		t, err := p.columnType(typeName)
+
+		if err != nil {
+			return err
+		}
+
+		decl.Columns = append(decl.Columns, Column{ column, t })
+	}
+
+	for p.peek().kind == tokIdent && declQualifiers[p.peek().text] {
+		p.next()
+	}
+
+	p.program.Decls[name] = decl
+	return nil
+}
+
+// A fact or a rule, possibly with several heads
+func (p *parser) clause() error {
+	line := p.peek().line
+	heads := make([]*atom, 0, 1)
+
+	for {
+		head, err := p.atom()
+
+		if err != nil {
+			return err
+		}
+
+		heads = append(heads, head)
+
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	if p.accept(".") {
+		for _, head := range heads {
+			for _, arg := range head.args {
+				if _, ok := arg.(constant); !ok {
+					return fmt.Errorf("line %d: facts may only contain constants", head.line)
+				}
+			}
+
+			p.program.facts = append(p.program.facts, head)
+		}
+
+		return nil
+	}
+
+	if err := p.expect(":-"); err != nil {
+		return err
+	}
+
+	body, err := p.disjunction()
+
+	if err != nil {
+		return err
+	}
+
+	if err := p.expect("."); err != nil {
+		return err
+	}
+
+	for _, head := range heads {
+		for _, alternative := range body {
+			p.program.rules = append(p.program.rules, &rule{ head, alternative, line })
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) atom() (*atom, error) {
+	line := p.peek().line
+	name, err := p.ident()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	a := &atom{ rel: name, line: line }
+
+	for !p.accept(")") {
+		if len(a.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		arg, err := p.expr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		a.args = append(a.args, arg)
+	}
+
+	return a, nil
+}
+
+// Alternatives of a body, each a list of atoms and comparisons
+func (p *parser) disjunction() ([][]interface{}, error) {
+	alternatives := make([][]interface{}, 0)
+
+	for {
+		conj, err := p.conjunction()
+
+		if err != nil {
+			return nil, err
+		}
+
+		alternatives = append(alternatives, conj...)
+
+		if !p.accept(";") {
+			return alternatives, nil
+		}
+	}
+}
+
+func (p *parser) conjunction() ([][]interface{}, error) {
+	alternatives := [][]interface{}{ {} }
+
+	for {
+		literal, err := p.literal()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Distribute the literal's alternatives over the ones so far
+		combined := make([][]interface{}, 0, len(alternatives) * len(literal))
+
+		for _, prefix := range alternatives {
+			for _, suffix := range literal {
+				joined := make([]interface{}, 0, len(prefix) + len(suffix))
+				joined = append(append(joined, prefix...), suffix...)
+				combined = append(combined, joined)
+			}
+		}
+
+		alternatives = combined
+
+		if !p.accept(",") {
+			return alternatives, nil
+		}
+	}
+}
+
+func (p *parser) literal() ([][]interface{}, error) {
+	if p.accept("!") {
+		a, err := p.atom()
+
+		if err != nil {
+			return nil, err
+		}
+
+		a.negated = true
+		return [][]interface{}{ { a } }, nil
+	}
+
+	if p.is("(") {
+		// A parenthesized expression may start a comparison, otherwise this
+		// is a nested body
+		start := p.pos
+
+		if c, err := p.comparison(); err == nil {
+			return [][]interface{}{ { c } }, nil
+		}
+
+		p.pos = start
+		p.next()
+
+		alternatives, err := p.disjunction()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return alternatives, nil
+	}
+
+	tok := p.peek()
+
+	if tok.kind == tokIdent && p.tokens[p.pos + 1].kind == tokPunct && p.tokens[p.pos + 1].text == "(" {
+		a, err := p.atom()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return [][]interface{}{ { a } }, nil
+	}
+
+	c, err := p.comparison()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]interface{}{ { c } }, nil
+}
+
+var comparisonOps = []string{ "=", "!=", "<", "<=", ">", ">=" }
+
+func (p *parser) comparison() (*comparison, error) {
+	line := p.peek().line
+	left, err := p.expr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range comparisonOps {
+		if p.accept(op) {
+			right, err := p.expr()
+
+			if err != nil {
+				return nil, err
+			}
+
+			return &comparison{ op, left, right, line }, nil
+		}
+	}
+
+	return nil, p.errorf("expected a comparison, found %s", describe(p.peek()))
+}
+
+func (p *parser) expr() (term, error) {
+	left, err := p.product()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is("+") || p.is("-") {
+		op := p.next().text
+		right, err := p.product()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = binary{ op, left, right }
+	}
+
+	return left, nil
+}
+
+func (p *parser) product() (term, error) {
+	left, err := p.factor()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is("*") || p.is("/") || p.is("%") {
+		op := p.next().text
+		right, err := p.factor()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = binary{ op, left, right }
+	}
+
+	return left, nil
+}
+
+func (p *parser) factor() (term, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokNumber:
+		n, err := parseNumber(tok.text)
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid number %s", tok.line, tok.text)
+		}
+
+		return constant{ n }, nil
+	case tokString:
+		return constant{ tok.text }, nil
+	case tokIdent:
+		if tok.text == "_" {
+			return wildcard{}, nil
+		}
+
+		return variable{ tok.text }, nil
+	case tokPunct:
+		switch tok.text {
+		case "(":
+			inner, err := p.expr()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+
+			return inner, nil
+		case "-":
+			inner, err := p.factor()
+
+			if err != nil {
+				return nil, err
+			}
+
+			return binary{ "-", constant{ int64(0) }, inner }, nil
+		}
+	}
+
+	return nil, fmt.Errorf("line %d: unexpected %s", tok.line, describe(tok))
+}
+
+// Check relations are declared and used with the right arity
+func (program *Program) check() error {
+	arity := func(a *atom) error {
+		decl, ok := program.Decls[a.rel]
+
+		if !ok {
+			return fmt.Errorf("line %d: relation %s isn't declared", a.line, a.rel)
+		}
+
+		if len(decl.Columns) != len(a.args) {
+			return fmt.Errorf("line %d: %s has %d columns, used with %d", a.line, a.rel, len(decl.Columns), len(a.args))
+		}
+
+		return nil
+	}
+
+	for _, fact := range program.facts {
+		if err := arity(fact); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range program.rules {
+		if err := arity(r.head); err != nil {
+			return err
+		}
+
+		for _, literal := range r.body {
+			if a, ok := literal.(*atom); ok {
+				if err := arity(a); err != nil {
+					return err
+				}
+
+				for _, arg := range a.args {
+					if _, ok := arg.(binary); ok {
+						return fmt.Errorf("line %d: arithmetic isn't supported inside body atoms, compare with a variable instead", a.line)
+					}
+				}
+			}
+		}
+
+		for _, arg := range r.head.args {
+			if _, ok := arg.(wildcard); ok {
+				return fmt.Errorf("line %d: _ can't appear in the head of a rule", r.line)
+			}
+		}
+	}
+
+	for _, names := range [][]string{ program.Inputs, program.Outputs } {
+		for _, name := range names {
+			if _, ok := program.Decls[name]; !ok {
+				return fmt.Errorf("relation %s isn't declared", name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -5,124 +5,138 @@ import (
 	"pbg/graph"
 	"encoding/base64"
 	"fmt"
-	"log"
-	"strconv"
+
+	"github.com/cayleygraph/cayley/quad"
 )
 
-func getBinaryEntryPoint(pbg *graph.ProgramBehaviorGraph, binaryObj string) uint64 {
-	entryPoints, err := pbg.Query(fmt.Sprintf("g.V('%s').Out('prog-entry-point').All()", binaryObj))
+func getBinaryEntryPoint(pbg *graph.ProgramBehaviorGraph, binaryId quad.IRI) (graph.Addr, error) {
+	entryPoints, err := pbg.V(binaryId).Out("prog-entry-point").Addrs()
 
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 
 	if len(entryPoints) == 0 {
-		panic(fmt.Sprintf("No entry points for binary %s", binaryObj))
+		return 0, fmt.Errorf("no entry points for binary %s", binaryId)
 	}
 
-	entryPoint, err := strconv.ParseUint(entryPoints[0][1:len(entryPoints[0])-1], 16, 64)
+	return entryPoints[0], nil
+}
+
+func getBinarySectionAddr(pbg *graph.ProgramBehaviorGraph, binaryId quad.IRI, section string) (graph.Addr, error) {
+	addrs, err := pbg.V(binaryId).Out("has-section").Has(graph.NamePredicate, "." + section).Out("elf-section-addr").Addrs()
 
 	if err != nil {
-		panic(err)
+		return 0, err
+	} 
+
+	if len(addrs) != 1 {
+		return 0, fmt.Errorf("expected one .%s section address in %s, found %d", section, binaryId, len(addrs))
 	}
 
-	return entryPoint
+	return addrs[0], nil
 }
 
-func getBinarySectionAddr(pbg *graph.ProgramBehaviorGraph, binaryObj string, section string) uint64 {
-	dataObjs, err := pbg.Query(fmt.Sprintf("g.V('%s').Out('has-section').Filter(regex('\\.%s')).Out('elf-section-addr').All()", binaryObj, section))
+func getBinarySectionData(pbg *graph.ProgramBehaviorGraph, binaryId quad.IRI, section string) ([]byte, error) {
+	dataObjs, err := pbg.V(binaryId).Out("has-section").Has(graph.NamePredicate, "." + section).Out("section-has-data").Strings()
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	} 
 
 	if len(dataObjs) != 1 {
-		panic("Invalid data found")
+		return nil, fmt.Errorf("expected one .%s section in %s, found %d", section, binaryId, len(dataObjs))
 	}
 
-	data := dataObjs[0]
-	data = data[1:len(data) - 1]
+	return base64.StdEncoding.DecodeString(dataObjs[0])
+}
 
-	addr, err := strconv.ParseUint(data, 16, 64)
+func loadBinary(pbg *graph.ProgramBehaviorGraph, binaryId quad.IRI) error {
+	entryAddr, err := getBinaryEntryPoint(pbg, binaryId)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	return addr
-}
-
-func getBinarySectionData(pbg *graph.ProgramBehaviorGraph, binaryObj string, section string) []byte {
-	dataObjs, err := pbg.Query(fmt.Sprintf("g.V('%s').Out('has-section').Filter(regex('\\.%s')).Out('section-has-data').All()", binaryObj, section))
+	address, err := getBinarySectionAddr(pbg, binaryId, "text")
 
 	if err != nil {
-		panic(err)
-	} 
-
-	if len(dataObjs) != 1 {
-		panic("Invalid data found")
+		return err
 	}
 
-	data := dataObjs[0][1:len(dataObjs[0]) - 1]
-
-	decoded_data, err := base64.StdEncoding.DecodeString(data)
+	binaryData, err := getBinarySectionData(pbg, binaryId, "text")
 
 	if err != nil {
-		panic(err);
+		return err
 	}
 
-	return decoded_data
-}
-
-func loadBinary(pbg *graph.ProgramBehaviorGraph, binaryObj string) {
-	entryAddr := getBinaryEntryPoint(pbg, binaryObj)
-	address := getBinarySectionAddr(pbg, binaryObj, "text")
-	binaryData := getBinarySectionData(pbg, binaryObj, "text")
-
-	log.Printf("Found text at 0x%x entry 0x%x from binary %s (%d bytes)\n", address, entryAddr, binaryObj, len(binaryData))
+	pbg.Logger().Printf("Found text at %s entry %s from binary %s (%d bytes)\n", address, entryAddr, binaryId, len(binaryData))
 
 	engine, err := gapstone.New(
 		gapstone.CS_ARCH_X86,
 		gapstone.CS_MODE_64,
 	)
 
+	if err != nil {
+		return err
+	}
+
 	defer engine.Close()
 
-	insns, err := engine.Disasm(binaryData, address, 0)
+	insns, err := engine.Disasm(binaryData, uint64(address), 0)
 
 	if err != nil {
-		panic(err);
+		return err;
 	}
 
-	pbg.AddRelationFunc(func (ch chan []string) {
+	pbg.AddRelationFunc(func (ch chan []interface{}) error {
 		for _, insn := range insns {
-			addrStr := fmt.Sprintf("0x%x", insn.Address)
+			// Instructions belong to their binary, joined to traces by address
+			insnAddr := graph.Addr(insn.Address)
+			insnId := graph.ChildID(binaryId, "insn", insnAddr.String())
 			opStr := fmt.Sprintf("%s %s", insn.Mnemonic, insn.OpStr)
-			ch <- []string { addrStr, "disassembles-to", opStr }
+
+			ch <- []interface{} { binaryId, "has-insn", insnId }
+			ch <- []interface{} { insnId, "at-address", insnAddr }
+			ch <- []interface{} { insnId, "disassembles-to", opStr }
 		}
 
-		close(ch)
+		return nil
 	})
 
-	log.Printf("Handled %d instructions\n", len(insns))
+	pbg.Logger().Printf("Handled %d instructions\n", len(insns))
+
+	return nil
 }
 
-func loadDisasm(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
-	binaryObjs, err := pbg.Query("g.V().In('prog-entry-point').All()");
+func loadDisasm(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
+	binaryIds, err := pbg.V().In("prog-entry-point").IRIs()
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	for _, binaryObj := range binaryObjs {
-		loadBinary(pbg, binaryObj[1:len(binaryObj) - 1])
+	for _, binaryId := range binaryIds {
+		if err := loadBinary(pbg, binaryId); err != nil {
+			return err
+		}
 	}
 
-	if err != nil {
-		panic(err)
-	}
+	return nil
 }
 
 func init() {
-	graph.RegisterProvider("disasm", loadDisasm, "elf")
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "disasm",
+		Run: loadDisasm,
+		Deps: []string{ "elf" },
+		Options: []graph.PBGOption{},
+		Produces: []string{ "has-insn", "at-address", "disassembles-to" },
+		Types: map[string] graph.PBGPredicateType{
+			"has-insn": { Subject: graph.ValueSymbol, Object: graph.ValueSymbol },
+			"at-address": { Subject: graph.ValueSymbol, Object: graph.ValueNumber },
+			"disassembles-to": { Subject: graph.ValueSymbol, Object: graph.ValueSymbol },
+		},
+		Consumes: []string{ graph.NamePredicate, "prog-entry-point", "has-section", "elf-section-addr", "section-has-data" },
+	})
 }
\ No newline at end of file
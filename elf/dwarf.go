@@ -4,26 +4,60 @@ import (
 	"pbg/graph"
 	"fmt"
 	"io"
-	"log"
+	"path/filepath"
+	"strconv"
 	"encoding/binary"
 	"debug/dwarf"
 	"ekyu.moe/leb128"
+	"github.com/cayleygraph/cayley/quad"
 )
 
 func dwarfOffsetId(offset int64) string {
 	return fmt.Sprintf("offset-%d", offset)
 }
 
-func dwarfTypeId(offset dwarf.Offset) string {
-	return fmt.Sprintf("dwarf-type-%d", offset)
+// Identities shared while walking the entries of a single compile unit
+type dwarfUnit struct {
+	binary quad.IRI
+	id quad.IRI
+	file quad.IRI
+	files []*dwarf.LineFile
 }
 
-// Parse a DW_AT_location
-func readDwarfLocation(pbg *graph.ProgramBehaviorGraph, varName string, data []byte, dwarfReader *dwarf.Reader) (string, error) {
+// Types are keyed by their offset, which is only unique within one binary
+func (unit *dwarfUnit) typeId(offset dwarf.Offset) quad.IRI {
+	return graph.ChildID(unit.binary, "type", strconv.FormatInt(int64(offset), 10))
+}
+
+// Find the source line of a declaration, preferring the file it names over
+// the compile unit's own file.
+func (unit *dwarfUnit) declLine(entry *dwarf.Entry) (quad.IRI, bool) {
+	lineNumField := entry.AttrField(dwarf.AttrDeclLine)
+
+	if lineNumField == nil || lineNumField.Val == nil {
+		return "", false
+	}
+
+	file := unit.file
+
+	if fileField := entry.AttrField(dwarf.AttrDeclFile); fileField != nil {
+		if index, ok := fileField.Val.(int64); ok && index >= 0 && int(index) < len(unit.files) && unit.files[index] != nil {
+			file = graph.FileID(unit.files[index].Name)
+		}
+	}
+
+	return graph.LineID(file, lineNumField.Val.(int64)), true
+}
+
+// Parse a DW_AT_location. A location that is just an address is returned as a
+// graph.Addr so it joins with the trace data, anything else as a string.
+func readDwarfLocation(pbg *graph.ProgramBehaviorGraph, varName string, data []byte, dwarfReader *dwarf.Reader) (interface{}, error) {
 	output := ""
+	ops := 0
 
 	for len(data) > 0 {
 		opcode := DwarfOpcode(data[0])
+		ops += 1
 
 		if opcode == DW_OP_fbreg {
 			// Framebuffer relative
@@ -43,20 +77,25 @@ func readDwarfLocation(pbg *graph.ProgramBehaviorGraph, varName string, data []b
 			// Raw address
 			data = data[1:]
 			addrSize := dwarfReader.AddressSize()
-			addr := binary.LittleEndian.Uint64(data[:addrSize])
+			addr := graph.Addr(binary.LittleEndian.Uint64(data[:addrSize]))
 			data = data[addrSize:]
 
-			output += fmt.Sprintf("0x%08x", addr)
+			if ops == 1 && len(data) == 0 {
+				return addr, nil
+			}
+
+			output += addr.String()
 		} else {
-			return "", fmt.Errorf("Unknown opcode %v (%v)", opcode.String(), data);
+			return nil, fmt.Errorf("Unknown opcode %v (%v)", opcode.String(), data);
 		}
 	}
 
 	return output, nil
 }
 
-// Parse a variable
-func readDwarfVariable(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, prefix string) (string, error) {
+// Parse a variable. Variables are scoped by the function, block or type that
+// declares them so every i in every function gets its own node.
+func readDwarfVariable(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit, scope quad.IRI) (quad.IRI, error) {
 	varNameField := entry.AttrField(dwarf.AttrName)
 
 	// Ignore name-less variables.
@@ -65,14 +104,14 @@ func readDwarfVariable(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwar
 	}
 
 	varName := varNameField.Val.(string)
-	log.Printf("Found variable %s\n", varName)
+	varId := graph.ChildID(scope, "var", varName)
+	pbg.Logger().Printf("Found variable %s\n", varName)
 
-	// Parse line number
-	lineNumField := entry.AttrField(dwarf.AttrDeclLine)
+	pbg.AddName(varId, varName)
 
-	if lineNumField != nil && lineNumField.Val != nil {
-		lineNum := lineNumField.Val.(int64)
-		pbg.AddRelation(varName, "decl-at", fmt.Sprintf("line-%d", lineNum))
+	// Parse line number
+	if lineId, ok := unit.declLine(entry); ok {
+		pbg.AddRelationValue(varId, "decl-at", lineId)
 	}
 
 	// Parse type id
@@ -80,7 +119,7 @@ func readDwarfVariable(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwar
 
 	if typeIdField != nil && typeIdField.Val != nil {
 		typeId  := typeIdField.Val.(dwarf.Offset)
-		pbg.AddRelation(varName, "has-var-type", dwarfTypeId(typeId))
+		pbg.AddRelationValue(varId, "has-var-type", unit.typeId(typeId))
 	}
 
 	// Parse runtime location
@@ -93,137 +132,163 @@ func readDwarfVariable(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwar
 
 		loc := locationField.Val.([]byte)
 
-		if locStr, err := readDwarfLocation(pbg, varName, loc, dwarfReader); err == nil {
-			log.Printf("Found variable %s at %s\n", varName, locStr)
-			pbg.AddRelation(varName, "runtime-at", locStr)
+		if locVal, err := readDwarfLocation(pbg, varName, loc, dwarfReader); err == nil {
+			pbg.Logger().Printf("Found variable %s at %v\n", varName, locVal)
+			pbg.AddRelationValue(varId, "runtime-at", locVal)
 		} else {
-			log.Printf("Failed to handle location: %v\n", err)
+			pbg.Logger().Printf("Failed to handle location: %v\n", err)
 		}
 	} else {
-		log.Printf("Variable doesn't have location field\n")
+		pbg.Logger().Printf("Variable doesn't have location field\n")
 	}
 
-	return varName, nil
+	return varId, nil
 }
 
 // Parse a parameter
-func readDwarfParameter(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, prefix string) (string, error) {
+func readDwarfParameter(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit, scope quad.IRI) (quad.IRI, error) {
 	// TODO - not this
-	return readDwarfVariable(pbg, entry, dwarfReader, prefix)
+	return readDwarfVariable(pbg, entry, dwarfReader, unit, scope)
 }
 
-// Parse a lexical block/function block
-func readDwarfBlock(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, funcName string) {
-	log.Println("Start parsing block")
+// Parse a lexical block/function block. Variables belong to the function but
+// are named within the innermost block.
+func readDwarfBlock(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit, funcId quad.IRI, scope quad.IRI) error {
+	pbg.Logger().Println("Start parsing block")
 
 	if entry.Children {
 		for {
 			entry, err := dwarfReader.Next()
 
-			if entry == nil {
-				break
+			if err != nil {
+				return err
 			}
 
-			if err != nil {
-				panic(err)
+			if entry == nil {
+				break
 			}
 
-			log.Printf("Found a %s in block\n", entry.Tag.GoString())
+			pbg.Logger().Printf("Found a %s in block\n", entry.Tag.GoString())
 
 			if entry.Tag == 0 {
-				log.Println("End of block")
+				pbg.Logger().Println("End of block")
 				break
 			}
 
 			if entry.Tag == dwarf.TagVariable {
-				if varName, err := readDwarfVariable(pbg, entry, dwarfReader, funcName); err == nil {
-					pbg.AddRelation(funcName, "has-var", varName)
+				if varId, err := readDwarfVariable(pbg, entry, dwarfReader, unit, scope); err == nil {
+					pbg.AddRelationValue(funcId, "has-var", varId)
 				} else {
-					log.Printf("Failed to handle variable: %v\n", err)
+					pbg.Logger().Printf("Failed to handle variable: %v\n", err)
 				}
 			} else if entry.Tag == dwarf.TagStructType {
-				readDwarfStructType(pbg, entry, dwarfReader)
+				if _, err := readDwarfStructType(pbg, entry, dwarfReader, unit); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagFormalParameter {
-				if paramName, err := readDwarfParameter(pbg, entry, dwarfReader, funcName); err == nil {
-					pbg.AddRelation(funcName, "has-var", paramName)
-					pbg.AddRelation(funcName, "has-param", paramName)
+				if paramId, err := readDwarfParameter(pbg, entry, dwarfReader, unit, scope); err == nil {
+					pbg.AddRelationValue(funcId, "has-var", paramId)
+					pbg.AddRelationValue(funcId, "has-param", paramId)
 				} else {
-					log.Printf("Failed to handle variable: %v\n", err)
+					pbg.Logger().Printf("Failed to handle variable: %v\n", err)
 				}
 			} else if entry.Tag == dwarf.TagLexDwarfBlock {
-				readDwarfBlock(pbg, entry, dwarfReader, funcName)
+				blockScope := graph.ChildID(scope, "scope", strconv.FormatInt(int64(entry.Offset), 10))
+				if err := readDwarfBlock(pbg, entry, dwarfReader, unit, funcId, blockScope); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagLabel {
 				continue
 			} else if entry.Tag == dwarf.TagSubprogram {
-				readDwarfFunction(pbg, entry, dwarfReader)
+				if _, err := readDwarfFunction(pbg, entry, dwarfReader, unit, scope); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagUnspecifiedParameters {
 				continue
 			} else if entry.Tag == dwarf.TagPointerType {
-				readDwarfPointerType(pbg, entry, dwarfReader)
+				readDwarfPointerType(pbg, entry, dwarfReader, unit)
 			} else if entry.Tag == dwarf.TagArrayType {
-				readDwarfArrayType(pbg, entry, dwarfReader)
+				if _, err := readDwarfArrayType(pbg, entry, dwarfReader, unit); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagEnumerationType {
-				readDwarfEnumeration(pbg, entry, dwarfReader)
+				if _, err := readDwarfEnumeration(pbg, entry, dwarfReader, unit); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagSubroutineType {
-				readDwarfSubroutineType(pbg, entry, dwarfReader)
+				if _, err := readDwarfSubroutineType(pbg, entry, dwarfReader, unit); err != nil {
+					return err
+				}
 			} else {
-				log.Printf("Unknown tag %v in block", entry.Tag)
+				pbg.Logger().Printf("Unknown tag %v in block", entry.Tag)
 				dwarfReader.SkipChildren()
 			}
 		}
 	}
 
 
-	log.Println("Done parsing block")
+	pbg.Logger().Println("Done parsing block")
+
+	return nil
 }
 
-// Parse a function
-func readDwarfFunction(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) (string, bool) {
+// Parse a function, returning an empty id for anonymous ones
+func readDwarfFunction(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit, scope quad.IRI) (quad.IRI, error) {
 	funcNameField := entry.AttrField(dwarf.AttrName)
 
 	if funcNameField == nil || funcNameField.Val == nil {
-		return "", false
+		return "", nil
 	}
 
 	funcName, ok := funcNameField.Val.(string)
 
 	if !ok {
-		panic(fmt.Sprintf("Failed to parse function name %v", funcNameField))
+		return "", fmt.Errorf("failed to parse function name %v", funcNameField)
 	}
 
-	log.Printf("Found function %s\n", funcName);
+	pbg.Logger().Printf("Found function %s\n", funcName);
+
+	funcId := graph.ChildID(scope, "func", funcName)
+	pbg.AddName(funcId, funcName)
+
+	if lineId, ok := unit.declLine(entry); ok {
+		pbg.AddRelationValue(funcId, "decl-at", lineId)
+	}
 
 	if entry.Children {
-		readDwarfBlock(pbg, entry, dwarfReader, funcName)
+		if err := readDwarfBlock(pbg, entry, dwarfReader, unit, funcId, funcId); err != nil {
+			return "", err
+		}
 	}
 
-	return funcName, true
+	return funcId, nil
 }
 
 // Parse a member type
-func readDwarfMemberType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+func readDwarfMemberType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) quad.IRI {
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 	memberNameField := entry.AttrField(dwarf.AttrName)
 
 	if memberNameField != nil && memberNameField.Val != nil {
 		if memberName, ok := memberNameField.Val.(string); ok {
-			pbg.AddRelation(dTypeId, "has-member-name", memberName)
+			pbg.AddRelationValue(dTypeId, "has-member-name", memberName)
 		}
 	}
 
-	memberTypeField := entry.AttrField(dwarf.AttrType)
-	memberType := dwarfTypeId(memberTypeField.Val.(dwarf.Offset))
-	pbg.AddRelation(dTypeId, "has-member-type", memberType)
+	if memberTypeField := entry.AttrField(dwarf.AttrType); memberTypeField != nil {
+		memberType := unit.typeId(memberTypeField.Val.(dwarf.Offset))
+		pbg.AddRelationValue(dTypeId, "has-member-type", memberType)
+	}
 
 	dataLocField := entry.AttrField(dwarf.AttrDataMemberLoc)
 
 	if dataLocField != nil {
 		if dataLocFieldRef, ok := dataLocField.Val.(int64); ok {
-			pbg.AddRelation(dTypeId, "has-data-offset", dwarfOffsetId(dataLocFieldRef))
+			pbg.AddRelationValue(dTypeId, "has-data-offset", dwarfOffsetId(dataLocFieldRef))
 		} else if dataLocFieldBlock, ok := dataLocField.Val.([]byte); ok {
-			log.Printf("Unable to handle block: %v\n", dataLocFieldBlock)
+			pbg.Logger().Printf("Unable to handle block: %v\n", dataLocFieldBlock)
 		} else {
-			panic(fmt.Sprintf("Unable to handle type %T in DML", dataLocField.Val))
+			pbg.Logger().Printf("Unable to handle type %T in DML\n", dataLocField.Val)
 		}
 	}
 
@@ -231,283 +296,283 @@ func readDwarfMemberType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dw
 }
 
 // Parse a struct
-func readDwarfStructType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
-	structName := readDwarfBaseType(pbg, entry, dwarfReader)
+func readDwarfStructType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) (quad.IRI, error) {
+	structName := readDwarfBaseType(pbg, entry, dwarfReader, unit)
 
 	if entry.Children {
 		for {
 			entry, err := dwarfReader.Next()
 
-			if entry == nil || entry.Tag == 0 {
-				break
+			if err != nil {
+				return "", err
 			}
 
-			if err != nil {
-				panic(err)
+			if entry == nil || entry.Tag == 0 {
+				break
 			}
 
 			if entry.Tag == dwarf.TagMember {
-				memberName := readDwarfMemberType(pbg, entry, dwarfReader)
-				pbg.AddRelation(structName, "has-member", memberName)
+				memberName := readDwarfMemberType(pbg, entry, dwarfReader, unit)
+				pbg.AddRelationValue(structName, "has-member", memberName)
 			}
 		}
 	}
 
-	return structName
+	return structName, nil
 }
 
 // Parse a base type
-func readDwarfBaseType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
+func readDwarfBaseType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) quad.IRI {
 	typeNameField := entry.AttrField(dwarf.AttrName)
 
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeNameField != nil {
 		typeName := typeNameField.Val.(string)
-		pbg.AddRelation(dTypeId, "has-type-name", typeName)
-		log.Printf("Found type %s\n", typeName)
+		pbg.AddRelationValue(dTypeId, "has-type-name", typeName)
+		pbg.Logger().Printf("Found type %s\n", typeName)
 	}
 
 	return dTypeId
 }
 
 // Parse a typedef
-func readDwarfTypedef(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
+func readDwarfTypedef(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) quad.IRI {
 	typeNameField := entry.AttrField(dwarf.AttrName)
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeNameField != nil {
 		typeName := typeNameField.Val.(string)
-		pbg.AddRelation(dTypeId, "has-type-name", typeName)
-		log.Printf("Found type %s\n", typeName)
+		pbg.AddRelationValue(dTypeId, "has-type-name", typeName)
+		pbg.Logger().Printf("Found type %s\n", typeName)
 	}
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		pbg.AddRelation(dTypeId, "has-real-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.AddRelationValue(dTypeId, "has-real-type", otherTypeId)
 	}
 
 	return dTypeId
 }
 
 // Parse a constant type
-func readDwarfConstType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+func readDwarfConstType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) quad.IRI {
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		log.Printf("Found type id %s", otherTypeId)
-		pbg.AddRelation(dTypeId, "const-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.Logger().Printf("Found type id %s", otherTypeId)
+		pbg.AddRelationValue(dTypeId, "const-type", otherTypeId)
 	}
 
 	return dTypeId
 }
 
 // Parse a restricted type
-func readDwarfRestrictType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+func readDwarfRestrictType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) quad.IRI {
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		log.Printf("Found type id %s", otherTypeId)
-		pbg.AddRelation(dTypeId, "restrict-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.Logger().Printf("Found type id %s", otherTypeId)
+		pbg.AddRelationValue(dTypeId, "restrict-type", otherTypeId)
 	}
 
 	return dTypeId
 }
 
 // Parse a pointer type
-func readDwarfPointerType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+func readDwarfPointerType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) quad.IRI {
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		log.Printf("Found type id %s", otherTypeId)
-		pbg.AddRelation(dTypeId, "pointer-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.Logger().Printf("Found type id %s", otherTypeId)
+		pbg.AddRelationValue(dTypeId, "pointer-type", otherTypeId)
 	}
 
 	return dTypeId
 }
 
 // Parse an array type
-func readDwarfArrayType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
+func readDwarfArrayType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) (quad.IRI, error) {
 	typeNameField := entry.AttrField(dwarf.AttrName)
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeNameField != nil {
 		typeName := typeNameField.Val.(string)
-		pbg.AddRelation(dTypeId, "has-type-name", typeName)
-		log.Printf("Found type %s\n", typeName)
+		pbg.AddRelationValue(dTypeId, "has-type-name", typeName)
+		pbg.Logger().Printf("Found type %s\n", typeName)
 	}
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		pbg.AddRelation(dTypeId, "array-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.AddRelationValue(dTypeId, "array-type", otherTypeId)
 	}
 
 	if entry.Children {
 		for {
 			entry, err := dwarfReader.Next()
 
-			if entry == nil {
-				break
+			if err != nil {
+				return "", err
 			}
 
-			if err != nil {
-				panic(err)
+			if entry == nil {
+				break
 			}
 
-			log.Printf("Found a %s in array\n", entry.Tag.GoString())
+			pbg.Logger().Printf("Found a %s in array\n", entry.Tag.GoString())
 
 			if entry.Tag == dwarf.TagSubrangeType {
-				subRange := readDwarfSubrangeType(pbg, entry, dwarfReader)
-				pbg.AddRelation(dTypeId, "has-subrange", subRange)
+				subRange := readDwarfSubrangeType(pbg, entry, dwarfReader, unit)
+				pbg.AddRelationValue(dTypeId, "has-subrange", subRange)
 			} else if entry.Tag == 0 {
 				break
 			} else {
-				log.Printf("Unknown tag %v in array type", entry.Tag)
+				pbg.Logger().Printf("Unknown tag %v in array type", entry.Tag)
 				dwarfReader.SkipChildren()
 			}
 		}
 	}
 	
-	return dTypeId
+	return dTypeId, nil
 }
 
 // Parse a subrange type
-func readDwarfSubrangeType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
+func readDwarfSubrangeType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) quad.IRI {
 	typeNameField := entry.AttrField(dwarf.AttrName)
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeNameField != nil {
 		typeName := typeNameField.Val.(string)
-		pbg.AddRelation(dTypeId, "has-type-name", typeName)
-		log.Printf("Found type %s\n", typeName)
+		pbg.AddRelationValue(dTypeId, "has-type-name", typeName)
+		pbg.Logger().Printf("Found type %s\n", typeName)
 	}
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		pbg.AddRelation(dTypeId, "enumerator-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.AddRelationValue(dTypeId, "enumerator-type", otherTypeId)
 	}
 
 	return dTypeId
 }
 
 // Parse an enumerator type
-func readDwarfEnumeratorType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
+func readDwarfEnumeratorType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) quad.IRI {
 	typeNameField := entry.AttrField(dwarf.AttrName)
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeNameField != nil {
 		typeName := typeNameField.Val.(string)
-		pbg.AddRelation(dTypeId, "has-type-name", typeName)
-		log.Printf("Found type %s\n", typeName)
+		pbg.AddRelationValue(dTypeId, "has-type-name", typeName)
+		pbg.Logger().Printf("Found type %s\n", typeName)
 	}
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		pbg.AddRelation(dTypeId, "subrange-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.AddRelationValue(dTypeId, "subrange-type", otherTypeId)
 	}
 
 	return dTypeId
 }
 
 // Parse an enumeration
-func readDwarfEnumeration(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
+func readDwarfEnumeration(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) (quad.IRI, error) {
 	typeNameField := entry.AttrField(dwarf.AttrName)
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeNameField != nil {
 		typeName := typeNameField.Val.(string)
-		pbg.AddRelation(dTypeId, "has-type-name", typeName)
-		log.Printf("Found type %s\n", typeName)
+		pbg.AddRelationValue(dTypeId, "has-type-name", typeName)
+		pbg.Logger().Printf("Found type %s\n", typeName)
 	}
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		pbg.AddRelation(dTypeId, "enumeration-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.AddRelationValue(dTypeId, "enumeration-type", otherTypeId)
 	}
 
 	if entry.Children {
 		for {
 			entry, err := dwarfReader.Next()
 
-			if entry == nil {
-				break
+			if err != nil {
+				return "", err
 			}
 
-			if err != nil {
-				panic(err)
+			if entry == nil {
+				break
 			}
 
-			log.Printf("Found a %s in enumeration\n", entry.Tag.GoString())
+			pbg.Logger().Printf("Found a %s in enumeration\n", entry.Tag.GoString())
 
 			if entry.Tag == dwarf.TagEnumerator {
-				subRange := readDwarfEnumeratorType(pbg, entry, dwarfReader)
-				pbg.AddRelation(dTypeId, "has-enumerator", subRange)
+				subRange := readDwarfEnumeratorType(pbg, entry, dwarfReader, unit)
+				pbg.AddRelationValue(dTypeId, "has-enumerator", subRange)
 			} else if entry.Tag == 0 {
 				break
 			} else {
-				log.Printf("Unknown tag %v in array type", entry.Tag)
+				pbg.Logger().Printf("Unknown tag %v in array type", entry.Tag)
 				dwarfReader.SkipChildren()
 			}
 		}
 	}
 
-	return dTypeId
+	return dTypeId, nil
 }
 
 // Parse a subroutine type
-func readDwarfSubroutineType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) string {
+func readDwarfSubroutineType(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) (quad.IRI, error) {
 	typeNameField := entry.AttrField(dwarf.AttrName)
-	dTypeId := dwarfTypeId(dwarf.Offset(entry.Offset))
+	dTypeId := unit.typeId(dwarf.Offset(entry.Offset))
 
 	if typeNameField != nil {
 		typeName := typeNameField.Val.(string)
-		pbg.AddRelation(dTypeId, "has-type-name", typeName)
-		log.Printf("Found type %s\n", typeName)
+		pbg.AddRelationValue(dTypeId, "has-type-name", typeName)
+		pbg.Logger().Printf("Found type %s\n", typeName)
 	}
 
 	if typeField := entry.AttrField(dwarf.AttrType); typeField != nil {
-		otherTypeId := dwarfTypeId(typeField.Val.(dwarf.Offset))
-		pbg.AddRelation(dTypeId, "subroutine-type", otherTypeId)
+		otherTypeId := unit.typeId(typeField.Val.(dwarf.Offset))
+		pbg.AddRelationValue(dTypeId, "subroutine-type", otherTypeId)
 	}
 
 	if entry.Children {
 		for {
 			entry, err := dwarfReader.Next()
 
-			if entry == nil {
-				break
+			if err != nil {
+				return "", err
 			}
 
-			if err != nil {
-				panic(err)
+			if entry == nil {
+				break
 			}
 
-			log.Printf("Found a %s in subroutine\n", entry.Tag.GoString())
+			pbg.Logger().Printf("Found a %s in subroutine\n", entry.Tag.GoString())
 
 			if entry.Tag == dwarf.TagFormalParameter {
-				if paramName, err := readDwarfParameter(pbg, entry, dwarfReader, "<>"); err == nil {
-					pbg.AddRelation(dTypeId, "has-var", paramName)
-					pbg.AddRelation(dTypeId, "has-param", paramName)
+				if paramId, err := readDwarfParameter(pbg, entry, dwarfReader, unit, dTypeId); err == nil {
+					pbg.AddRelationValue(dTypeId, "has-var", paramId)
+					pbg.AddRelationValue(dTypeId, "has-param", paramId)
 				} else {
-					log.Printf("Failed to handle variable: %v\n", err)
+					pbg.Logger().Printf("Failed to handle variable: %v\n", err)
 				}
 			} else if entry.Tag == 0 {
 				break
 			} else {
-				log.Printf("Unknown tag %v in subroutine type", entry.Tag)
+				pbg.Logger().Printf("Unknown tag %v in subroutine type", entry.Tag)
 				dwarfReader.SkipChildren()
 			}
 		}
 	}
 
-	return dTypeId
+	return dTypeId, nil
 }
 
 // Parse a LineEntry
-func readDwarfCULine(pbg *graph.ProgramBehaviorGraph, cuName string, lineReader *dwarf.LineReader) {
+func readDwarfCULine(pbg *graph.ProgramBehaviorGraph, unit *dwarfUnit, lineReader *dwarf.LineReader) error {
 	var entry dwarf.LineEntry
 
 	for {
@@ -518,106 +583,172 @@ func readDwarfCULine(pbg *graph.ProgramBehaviorGraph, cuName string, lineReader
 				break
 			}
 
-			panic(err)
+			return err
 		}
 
-		locName := cuName + ":" + fmt.Sprintf("line-%d", entry.Line)
-		pbg.AddRelation(locName, "text-at-pc", fmt.Sprintf("0x%08x", entry.Address))
+		file := unit.file
+
+		if entry.File != nil {
+			file = graph.FileID(entry.File.Name)
+		}
+
+		pbg.AddRelationValue(graph.LineID(file, int64(entry.Line)), "text-at-pc", graph.Addr(entry.Address))
 	}
+
+	return nil
 }
 
 // Parse a compile unit
-func readDwarfCU(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader) {
-	cuNameField := entry.AttrField(dwarf.AttrName)
-	cuName := cuNameField.Val.(string)
-
+func readDwarfCU(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, dwarfReader *dwarf.Reader, unit *dwarfUnit) error {
 	if entry.Children {
 		for {
 			entry, err := dwarfReader.Next()
 
-			if entry == nil {
-				break
+			if err != nil {
+				return err
 			}
 
-			if err != nil {
-				panic(err)
+			if entry == nil {
+				break
 			}
 
-			log.Printf("Found a %s in compilation unit\n", entry.Tag.GoString())
+			pbg.Logger().Printf("Found a %s in compilation unit\n", entry.Tag.GoString())
 
 			if entry.Tag == dwarf.TagSubprogram {
-				if funcName, ok := readDwarfFunction(pbg, entry, dwarfReader); ok {
-					pbg.AddRelation(cuName, "defined-in", funcName);
+				funcId, err := readDwarfFunction(pbg, entry, dwarfReader, unit, unit.id)
+
+				if err != nil {
+					return err
+				}
+
+				if funcId != "" {
+					pbg.AddRelationValue(unit.id, "defined-in", funcId);
 				}
 			} else if entry.Tag == dwarf.TagBaseType {
-				readDwarfBaseType(pbg, entry, dwarfReader)
+				readDwarfBaseType(pbg, entry, dwarfReader, unit)
 			} else if entry.Tag == dwarf.TagStructType || entry.Tag == dwarf.TagUnionType {
-				readDwarfStructType(pbg, entry, dwarfReader)
+				if _, err := readDwarfStructType(pbg, entry, dwarfReader, unit); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagTypedef {
-				readDwarfTypedef(pbg, entry, dwarfReader)
+				readDwarfTypedef(pbg, entry, dwarfReader, unit)
 			} else if entry.Tag == dwarf.TagConstType {
-				readDwarfConstType(pbg, entry, dwarfReader)
+				readDwarfConstType(pbg, entry, dwarfReader, unit)
 			} else if entry.Tag == dwarf.TagRestrictType {
-				readDwarfRestrictType(pbg, entry, dwarfReader)
+				readDwarfRestrictType(pbg, entry, dwarfReader, unit)
 			} else if entry.Tag == dwarf.TagPointerType {
-				readDwarfPointerType(pbg, entry, dwarfReader)
+				readDwarfPointerType(pbg, entry, dwarfReader, unit)
 			} else if entry.Tag == dwarf.TagArrayType {
-				readDwarfArrayType(pbg, entry, dwarfReader)
+				if _, err := readDwarfArrayType(pbg, entry, dwarfReader, unit); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagEnumerationType {
-				readDwarfEnumeration(pbg, entry, dwarfReader)
+				if _, err := readDwarfEnumeration(pbg, entry, dwarfReader, unit); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagSubroutineType {
-				readDwarfSubroutineType(pbg, entry, dwarfReader)
+				if _, err := readDwarfSubroutineType(pbg, entry, dwarfReader, unit); err != nil {
+					return err
+				}
 			} else if entry.Tag == dwarf.TagVariable {
-				if varName, err := readDwarfVariable(pbg, entry, dwarfReader, "<global>"); err == nil {
-					pbg.AddRelation(cuName, "has-global-var", varName)
+				if varId, err := readDwarfVariable(pbg, entry, dwarfReader, unit, unit.id); err == nil {
+					pbg.AddRelationValue(unit.id, "has-global-var", varId)
 				} else {
-					log.Printf("Failed to handle variable: %v\n", err)
+					pbg.Logger().Printf("Failed to handle variable: %v\n", err)
 				}
 			} else if entry.Tag == dwarf.TagFormalParameter {
 				// This happens to a subprogram thats declared at the end of a function
 				// which has no sibling
-				log.Printf("Dangling parameter, but can't handle")
+				pbg.Logger().Printf("Dangling parameter, but can't handle")
 				continue
 			} else if entry.Tag == 0 {
 				break
 			} else {
-				log.Printf("Unknown tag %v in CU", entry.Tag)
+				pbg.Logger().Printf("Unknown tag %v in CU", entry.Tag)
 				dwarfReader.SkipChildren()
 			}
 		}
 	}
+
+	return nil
 }
 
-func readDwarf(pbg *graph.ProgramBehaviorGraph, dwarfObj *dwarf.Data) {
+// Build the identities for a compile unit of the given binary
+func newDwarfUnit(pbg *graph.ProgramBehaviorGraph, entry *dwarf.Entry, binaryId quad.IRI) (*dwarfUnit, error) {
+	cuName, ok := entry.Val(dwarf.AttrName).(string)
+
+	if !ok {
+		return nil, fmt.Errorf("compile unit at %d has no name", entry.Offset)
+	}
+	cuPath := cuName
+
+	// Relative names are resolved against the compilation directory so they
+	// match the absolute paths used by the files provider
+	if compDirField := entry.AttrField(dwarf.AttrCompDir); compDirField != nil && !filepath.IsAbs(cuPath) {
+		if compDir, ok := compDirField.Val.(string); ok {
+			cuPath = filepath.Join(compDir, cuPath)
+		}
+	}
+
+	unit := &dwarfUnit{
+		binary: binaryId,
+		id: graph.ChildID(binaryId, "cu", cuName),
+		file: graph.FileID(cuPath),
+	}
+
+	pbg.AddName(unit.id, cuName)
+	pbg.AddRelationValue(binaryId, "has-cu", unit.id)
+
+	return unit, nil
+}
+
+func readDwarf(pbg *graph.ProgramBehaviorGraph, dwarfObj *dwarf.Data, binaryId quad.IRI) error {
 	dwarfReader := dwarfObj.Reader()
 	pbg.SetAutoBulk(1000)
+	defer pbg.SetAutoBulk(0)
 
 	for {
 		entry, err := dwarfReader.Next()
 
-		if entry == nil {
-			break
+		if err != nil {
+			return err
 		}
 
-		if err != nil {
-			panic(err)
+		if entry == nil {
+			break
 		}
 
-		log.Printf("Found a %s\n", entry.Tag.GoString())
+		pbg.Logger().Printf("Found a %s\n", entry.Tag.GoString())
 
 		if entry.Tag == dwarf.TagCompileUnit {
+			unit, err := newDwarfUnit(pbg, entry, binaryId)
+
+			if err != nil {
+				return err
+			}
+
 			lineReader, err := dwarfObj.LineReader(entry)
 
 			if err != nil {
-				panic(err)
+				return err
+			}
+
+			// The file table is complete once the line program has been read
+			if lineReader != nil {
+				if err := readDwarfCULine(pbg, unit, lineReader); err != nil {
+					return err
+				}
+
+				unit.files = lineReader.Files()
 			}
 
-			readDwarfCULine(pbg, entry.AttrField(dwarf.AttrName).Val.(string), lineReader)
-			readDwarfCU(pbg, entry, dwarfReader)
+			if err := readDwarfCU(pbg, entry, dwarfReader, unit); err != nil {
+				return err
+			}
 		} else if entry.Tag == dwarf.TagBaseType {
-			panic(fmt.Errorf("Unknown tag %v at root", entry.Tag))
+			return fmt.Errorf("unknown tag %v at root", entry.Tag)
 		}
 	}
 
-	pbg.SetAutoBulk(0)
+	return nil
 }
@@ -2,76 +2,137 @@ package elf
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"pbg/graph"
+	"path/filepath"
 	"debug/elf"
 	"encoding/base64"
 	"strconv"
 );
 
-func loadElf(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
-	binaryObj, ok := opt["binary"].(string);
+// Load a single binary or library into the graph
+func loadBinary(pbg *graph.ProgramBehaviorGraph, binaryObj string) error {
+	file, err := os.Open(binaryObj)
 
-	// If none found, ignore
-	if !ok {
-		return;
+	if err != nil {
+		return err
 	}
 
-	file, err := os.Open(binaryObj)
+	defer file.Close()
+
+	elfobj, err := elf.NewFile(file)
 
 	if err != nil {
-		panic("Failed to open binary object for elf")
+		return fmt.Errorf("invalid elf object %s: %v", binaryObj, err)
 	}
 
-	elfobj, err := elf.NewFile(file)
+	binaryPath, err := filepath.Abs(binaryObj)
 
 	if err != nil {
-		panic("Invalid elf object")
+		return err
 	}
 
-	log.Printf("Entry Point: from %s 0x%08x\n", binaryObj, elfobj.Entry)
+	binaryId := graph.BinaryID(binaryPath)
+	pbg.AddName(binaryId, binaryObj)
+
+	pbg.Logger().Printf("Entry Point: from %s 0x%08x\n", binaryObj, elfobj.Entry)
 
-	pbg.AddRelation(binaryObj, "prog-entry-point", fmt.Sprintf("%08x", elfobj.Entry));
+	pbg.AddRelationValue(binaryId, "prog-entry-point", graph.Addr(elfobj.Entry));
 
 	for _, section := range elfobj.Sections {
 		sectionName := section.SectionHeader.Name
-		sectionAddr := strconv.FormatUint(section.SectionHeader.Addr, 16)
+		sectionId := graph.ChildID(binaryId, "section", sectionName)
+		sectionAddr := graph.Addr(section.SectionHeader.Addr)
 		_sectionSize := section.SectionHeader.Size
 		sectionSize := strconv.FormatUint(_sectionSize, 16)
 
-		pbg.AddRelation(binaryObj, "has-section", sectionName);
-		pbg.AddRelation(sectionName, "elf-section-size", sectionSize)
-		pbg.AddRelation(sectionName, "elf-section-addr", sectionAddr)
+		pbg.AddName(sectionId, sectionName)
+		pbg.AddRelationValue(binaryId, "has-section", sectionId);
+		pbg.AddRelationValue(sectionId, "elf-section-size", int64(_sectionSize))
+		pbg.AddRelationValue(sectionId, "elf-section-addr", sectionAddr)
 
 		data := make([]byte, _sectionSize);
 		n, err := section.ReaderAt.ReadAt(data, 0);
 
 		if err != nil {
-			log.Printf("Failed to get section %s\n", sectionName);
+			pbg.Logger().Printf("Failed to get section %s\n", sectionName);
 			continue;
 		}
 
 		if uint64(n) != _sectionSize {
-			panic("Failed to read all bytes");
+			return fmt.Errorf("failed to read all bytes of section %s", sectionName);
 		}
 
-		log.Printf("Reading section %s length %s at 0x%s\n", sectionName, sectionSize, sectionAddr);
+		pbg.Logger().Printf("Reading section %s length 0x%s at %s\n", sectionName, sectionSize, sectionAddr);
 
 		encoded_data := base64.StdEncoding.EncodeToString(data)
-		pbg.AddRelation(sectionName, "section-has-data", string(encoded_data));
+		pbg.AddRelationValue(sectionId, "section-has-data", string(encoded_data));
 	}
 
 	dwarfobj, err := elfobj.DWARF()
 
 	if err != nil {
-		log.Printf("Failed to find dwarf data (%v) skipping...", err)
-		return
+		pbg.Logger().Printf("Failed to find dwarf data (%v) skipping...", err)
+		return nil
+	}
+
+	if err := readDwarf(pbg, dwarfobj, binaryId); err != nil {
+		return fmt.Errorf("failed to read dwarf data of %s: %v", binaryObj, err)
+	}
+
+	return nil
+}
+
+func loadElf(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
+	binaryObjs := make([]string, 0)
+
+	if binaryObj, ok := opt["binary"].(string); ok {
+		binaryObjs = append(binaryObjs, binaryObj)
+	}
+
+	// Libraries or additional executables sharing the database
+	if _binaryObjs, ok := opt["binaries"].([]string); ok {
+		binaryObjs = append(binaryObjs, _binaryObjs...)
 	}
 
-	readDwarf(pbg, dwarfobj)
+	// If none found, this ignores the stage
+	for _, binaryObj := range binaryObjs {
+		if err := loadBinary(pbg, binaryObj); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func init() {
-	graph.RegisterProvider("elf", loadElf)
-}
\ No newline at end of file
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "elf",
+		Run: loadElf,
+		Options: []graph.PBGOption{
+			{ Name: "binary", Type: graph.OptionString, Description: "executable to load" },
+			{ Name: "binaries", Type: graph.OptionStringList, Description: "libraries or additional executables to load" },
+		},
+		Produces: []string{
+			graph.NamePredicate, "prog-entry-point", "has-section", "elf-section-size",
+			"elf-section-addr", "section-has-data", "has-cu", "defined-in", "decl-at",
+			"text-at-pc", "has-var", "has-global-var", "has-param", "has-var-type",
+			"runtime-at", "has-type-name", "has-member", "has-member-name",
+			"has-member-type", "has-data-offset", "has-real-type", "const-type",
+			"restrict-type", "pointer-type", "array-type", "has-subrange",
+			"subrange-type", "enumerator-type", "has-enumerator", "enumeration-type",
+			"subroutine-type",
+		},
+		Types: map[string] graph.PBGPredicateType{
+			graph.NamePredicate: { Subject: graph.ValueSymbol, Object: graph.ValueSymbol },
+			"prog-entry-point": { Subject: graph.ValueSymbol, Object: graph.ValueNumber },
+			"elf-section-size": { Subject: graph.ValueSymbol, Object: graph.ValueNumber },
+			"elf-section-addr": { Subject: graph.ValueSymbol, Object: graph.ValueNumber },
+			"section-has-data": { Subject: graph.ValueSymbol, Object: graph.ValueSymbol },
+			"text-at-pc": { Subject: graph.ValueSymbol, Object: graph.ValueNumber },
+			"decl-at": { Subject: graph.ValueSymbol, Object: graph.ValueSymbol },
+			"has-type-name": { Subject: graph.ValueSymbol, Object: graph.ValueSymbol },
+			"has-member-name": { Subject: graph.ValueSymbol, Object: graph.ValueSymbol },
+		},
+	})
+}
@@ -2,7 +2,6 @@ package files
 
 import (
 	"fmt"
-	"log"
 	"strings"
 	"io/ioutil"
 	"path/filepath"
@@ -10,88 +9,122 @@ import (
 )
 
 // Add a source file to a PBG
-func addSourceFile(pbg *graph.ProgramBehaviorGraph, sourceFile string) {
-	sourceDir, sourceFile := filepath.Split(sourceFile);
+func addSourceFile(pbg *graph.ProgramBehaviorGraph, sourcePath string) error {
+	sourcePath, err := filepath.Abs(sourcePath)
 
-	log.Printf("Adding source file %s from %s\n", sourceFile, sourceDir	);
+	if err != nil {
+		return err
+	}
+
+	sourceDir, sourceFile := filepath.Split(sourcePath)
+
+	pbg.Logger().Printf("Adding source file %s from %s\n", sourceFile, sourceDir)
+
+	dirId := graph.NodeID("dir", filepath.Clean(sourceDir))
+	fileId := graph.FileID(sourcePath)
 
-	pbg.AddRelation(sourceDir, "contains-file", sourceFile);
+	pbg.AddName(dirId, sourceDir)
+	pbg.AddName(fileId, sourceFile)
+	pbg.AddRelationValue(dirId, "contains-file", fileId)
+	pbg.AddRelationValue(fileId, "has-path", sourcePath)
 
-	data, err := ioutil.ReadFile(sourceDir + sourceFile);
+	data, err := ioutil.ReadFile(sourcePath)
 
 	if err != nil {
-		panic(err);
+		return err
 	}
 
 	content := string(data)
-	pbg.AddRelation(sourceFile, "has-text", content);
+	pbg.AddRelationValue(fileId, "has-text", content)
 
-	lines := strings.Split(content, "\n")	
+	lines := strings.Split(content, "\n")
 	index := 1
 
-	pbg.AddRelationFunc(func(ch chan []string) {
+	pbg.AddRelationFunc(func(ch chan []interface{}) error {
 		for _, line := range lines {
-			lineStr := fmt.Sprintf("line-%d", index)
+			lineId := graph.LineID(fileId, int64(index))
 
-			ch <- []string { sourceFile, "has-line", lineStr }
-			ch <- []string { sourceFile + ":"+ lineStr, "line-content", line }
+			ch <- []interface{} { fileId, "has-line", lineId }
+			ch <- []interface{} { lineId, graph.NamePredicate, fmt.Sprintf("%s:%d", sourceFile, index) }
+			ch <- []interface{} { lineId, "line-content", line }
 
 			index += 1
 		}
 
-		close(ch);
-	});
+		return nil
+	})
 
-	log.Printf("Added %d lines of code\n", index)
+	pbg.Logger().Printf("Added %d lines of code\n", index)
+
+	return nil
 }
 
 // Add all files found to PBG
-func addSourceFiles(pbg *graph.ProgramBehaviorGraph, sourceFiles []string) {
+func addSourceFiles(pbg *graph.ProgramBehaviorGraph, sourceFiles []string) error {
 	for _, sourceFile := range sourceFiles {
 		// Make sure we didn't get a directory
 		if filepath.Base(sourceFile) == "" {
-			panic("directory found in glob");
+			return fmt.Errorf("directory %s found in glob", sourceFile)
 		}
 
-		addSourceFile(pbg, sourceFile);
+		if err := addSourceFile(pbg, sourceFile); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
-func loadsFiles(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
-	log.Printf("Loading stuff: %v\n", opt);
+func loadsFiles(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
+	pbg.Logger().Printf("Loading stuff: %v\n", opt)
 	// First attempt to load from a glob
-	sourceGlob, ok := opt["sourceGlob"].(string);
+	sourceGlob, ok := opt["sourceGlob"].(string)
 
-	if ok  {
-		fmt.Printf("Loading glob %s\n", sourceGlob)
+	if ok {
+		pbg.Logger().Printf("Loading glob %s\n", sourceGlob)
 
-		sourceFiles, err := filepath.Glob(sourceGlob);
+		sourceFiles, err := filepath.Glob(sourceGlob)
 
-		if err != nil { 
-			panic(err);
+		if err != nil {
+			return err
 		}
 
-		fmt.Printf("Files found: %v\n", sourceFiles);
+		pbg.Logger().Printf("Files found: %v\n", sourceFiles)
 
-		addSourceFiles(pbg, sourceFiles);
+		if err := addSourceFiles(pbg, sourceFiles); err != nil {
+			return err
+		}
 	} else {
-		fmt.Println("No glob found")
+		pbg.Logger().Println("No glob found")
 	}
 
 	// Then attempt to load directly referenced files
-	_sourceFiles, ok := opt["sourceFiles"].([]interface {});
-
-	if ok {
-		sourceFiles := make([]string, 0)
-
-		for _, sourceFile := range _sourceFiles {
-			sourceFiles = append(sourceFiles, sourceFile.(string))
-		}
-
-		addSourceFiles(pbg, sourceFiles);
+	if sourceFiles, ok := opt["sourceFiles"].([]string); ok {
+		return addSourceFiles(pbg, sourceFiles)
 	}
+
+	return nil
 }
 
+// Everything files produces is text, even lines that happen to hold a number
+var textType = graph.PBGPredicateType{ Subject: graph.ValueSymbol, Object: graph.ValueSymbol }
+
 func init() {
-	graph.RegisterProvider("files", loadsFiles);
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "files",
+		Run: loadsFiles,
+		Options: []graph.PBGOption{
+			{ Name: "sourceGlob", Type: graph.OptionString, Description: "glob matching the source files to load" },
+			{ Name: "sourceFiles", Type: graph.OptionStringList, Description: "source files to load" },
+		},
+		Produces: []string{ graph.NamePredicate, "contains-file", "has-path", "has-text", "has-line", "line-content" },
+		Types: map[string] graph.PBGPredicateType{
+			graph.NamePredicate: textType,
+			"contains-file": textType,
+			"has-path": textType,
+			"has-text": textType,
+			"has-line": textType,
+			"line-content": textType,
+		},
+	})
 }
\ No newline at end of file
@@ -6,6 +6,7 @@ require (
 	ekyu.moe/leb128 v0.0.0-20190626180622-d3722dc409a8
 	github.com/bnagy/gapstone v0.0.0-20190828052830-ede92aaeaba7
 	github.com/cayleygraph/cayley v0.7.7
+	github.com/cayleygraph/quad v1.1.0
 	github.com/cznic/mathutil v0.0.0-20181122101859-297441e03548 // indirect
 	github.com/dennwc/graphql v0.4.18 // indirect
 	github.com/dlclark/regexp2 v1.2.0 // indirect
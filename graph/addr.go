@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// A program address. Every provider stores addresses through this type so
+// that the same location reported by the ELF headers, DWARF and DynamoRIO
+// ends up as the same node and compares as a number in Gizmo and Datalog.
+type Addr uint64
+
+// Parse an address as printed by one of the tools we consume. Values with a
+// 0x prefix are read as hex, everything else as decimal.
+func ParseAddr(s string) (Addr, error) {
+	s = strings.TrimSpace(s)
+
+	var addr uint64
+	var err error
+
+	if len(s) > 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		addr, err = strconv.ParseUint(s[2:], 16, 64)
+	} else {
+		addr, err = strconv.ParseUint(s, 10, 64)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+
+	return Addr(addr), nil
+}
+
+// Convert a value read back from the graph into an address
+func AddrOf(v quad.Value) (Addr, bool) {
+	switch v := v.(type) {
+	case quad.Int:
+		return Addr(v), true
+	case quad.String:
+		addr, err := ParseAddr(string(v))
+		return addr, err == nil
+	}
+
+	return 0, false
+}
+
+// The canonical quad value of an address. Quad integers are signed, so
+// addresses at or above 2^63, such as kernel ones, are stored as the negative
+// number sharing their bits; AddrOf turns them back into the same address.
+func (a Addr) Value() quad.Value {
+	return quad.Int(int64(a))
+}
+
+func (a Addr) String() string {
+	return fmt.Sprintf("0x%x", uint64(a))
+}
+
+// Convert anything a provider hands us into a quad value. Plain strings keep
+// their historic behaviour of becoming string nodes.
+func toValue(v interface{}) (quad.Value, error) {
+	switch v := v.(type) {
+	case Addr:
+		return v.Value(), nil
+	case quad.Value:
+		return v, nil
+	case string:
+		return quad.String(v), nil
+	}
+
+	if val, ok := quad.AsValue(v); ok {
+		return val, nil
+	}
+
+	return nil, fmt.Errorf("unable to store %T in the graph", v)
+}
+
+// Render a value without the quoting and type decoration of quad.StringOf
+func nativeString(v quad.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case quad.String:
+		return string(v)
+	case quad.IRI:
+		return string(v)
+	case quad.BNode:
+		return string(v)
+	case quad.Int:
+		return strconv.FormatInt(int64(v), 10)
+	case quad.TypedString:
+		return string(v.Value)
+	}
+
+	return quad.StringOf(v)
+}
@@ -0,0 +1,265 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/writer"
+	"github.com/cayleygraph/quad/jsonld"
+	"github.com/cayleygraph/quad/nquads"
+	"github.com/cayleygraph/quad/pquads"
+)
+
+// Formats a whole graph can be exported to and imported from. pquads is
+// Cayley's protobuf encoding and the most compact of them.
+var PBGArchiveFormats = []string{ "nquads", "jsonld", "graphml", "pquads" }
+
+// Largest single quad read from a pquads archive. Section data is stored as
+// one value, so this is generous.
+const archiveMaxQuadSize = 256 << 20
+
+// RDF only allows IRIs as predicates and IRIs or blank nodes as subjects, so
+// N-Quads and JSON-LD spell pbg's string predicates and literal subjects,
+// such as addresses, as IRIs under these prefixes. Reading them back turns
+// them into what they were.
+const (
+	rdfPredicatePrefix = idPrefix + "pred/"
+	rdfLiteralPrefix = idPrefix + "literal/"
+)
+
+// Spell a quad the way RDF tools accept it
+func toRDF(q quad.Quad) quad.Quad {
+	if predicate, ok := q.Predicate.(quad.String); ok {
+		q.Predicate = quad.IRI(rdfPredicatePrefix + url.PathEscape(string(predicate)))
+	}
+
+	switch q.Subject.(type) {
+	case quad.IRI, quad.BNode:
+	default:
+		q.Subject = quad.IRI(rdfLiteralPrefix + url.PathEscape(FormatValue(q.Subject)))
+	}
+
+	return q
+}
+
+// Undo toRDF
+func fromRDF(q quad.Quad) quad.Quad {
+	if iri, ok := q.Predicate.(quad.IRI); ok && strings.HasPrefix(string(iri), rdfPredicatePrefix) {
+		if name, err := url.PathUnescape(string(iri)[len(rdfPredicatePrefix):]); err == nil {
+			q.Predicate = quad.String(name)
+		}
+	}
+
+	if iri, ok := q.Subject.(quad.IRI); ok && strings.HasPrefix(string(iri), rdfLiteralPrefix) {
+		if text, err := url.PathUnescape(string(iri)[len(rdfLiteralPrefix):]); err == nil {
+			q.Subject = ParseValue(text)
+		}
+	}
+
+	return q
+}
+
+type archiveWriter interface {
+	WriteQuad(quad.Quad) error
+	Close() error
+}
+
+type archiveReader interface {
+	ReadQuad() (quad.Quad, error)
+	Close() error
+}
+
+// Writes quads of an RDF format, see toRDF
+type rdfWriter struct {
+	archiveWriter
+}
+
+func (w rdfWriter) WriteQuad(q quad.Quad) error {
+	return w.archiveWriter.WriteQuad(toRDF(q))
+}
+
+// Reads quads of an RDF format, see fromRDF
+type rdfReader struct {
+	archiveReader
+}
+
+func (r rdfReader) ReadQuad() (quad.Quad, error) {
+	q, err := r.archiveReader.ReadQuad()
+	return fromRDF(q), err
+}
+
+// Guess the format of an archive from its file name, and whether it is
+// gzipped, e.g. graph.nq.gz. The format is empty when the name doesn't tell.
+func ArchiveFormatOf(path string) (string, bool) {
+	gzipped := strings.HasSuffix(path, ".gz")
+
+	switch filepath.Ext(strings.TrimSuffix(path, ".gz")) {
+	case ".nq", ".nt":
+		return "nquads", gzipped
+	case ".jsonld":
+		return "jsonld", gzipped
+	case ".graphml":
+		return "graphml", gzipped
+	case ".pq", ".pquads":
+		return "pquads", gzipped
+	}
+
+	return "", gzipped
+}
+
+func unknownArchiveFormat(format string) error {
+	return fmt.Errorf("unknown archive format %s, expected one of %s", format, strings.Join(PBGArchiveFormats, ", "))
+}
+
+func newArchiveWriter(w io.Writer, format string) (archiveWriter, error) {
+	switch format {
+	case "nquads":
+		return rdfWriter{ nquads.NewWriter(w) }, nil
+	case "jsonld":
+		return rdfWriter{ jsonld.NewWriter(w) }, nil
+	case "graphml":
+		return newGraphMLWriter(w), nil
+	case "pquads":
+		return pquads.NewWriter(w, nil), nil
+	}
+
+	return nil, unknownArchiveFormat(format)
+}
+
+func newArchiveReader(r io.Reader, format string) (archiveReader, error) {
+	switch format {
+	case "nquads":
+		return rdfReader{ nquads.NewReader(r, false) }, nil
+	case "jsonld":
+		return rdfReader{ jsonld.NewReader(r) }, nil
+	case "graphml":
+		return newGraphMLReader(r), nil
+	case "pquads":
+		return pquads.NewReader(r, archiveMaxQuadSize), nil
+	}
+
+	return nil, unknownArchiveFormat(format)
+}
+
+// Write every quad of the graph, labels included, in one of PBGArchiveFormats.
+// Quads are streamed from the store as they are written, except that JSON-LD
+// documents are held in memory until complete, which doesn't suit large
+// graphs. Returns the number of quads written.
+func (pbg *ProgramBehaviorGraph) Export(w io.Writer, format string) (int, error) {
+	out, err := newArchiveWriter(w, format)
+
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel, timeout := pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	// Stop iterating as soon as a write fails
+	iterCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	count := 0
+	var writeErr error
+
+	it := pbg.store.QuadsAllIterator()
+	defer it.Close()
+
+	err = graph.Iterate(iterCtx, it).Each(func(ref graph.Ref) {
+		if writeErr != nil {
+			return
+		}
+
+		if writeErr = out.WriteQuad(pbg.store.Quad(ref)); writeErr != nil {
+			stop()
+			return
+		}
+
+		count += 1
+	})
+
+	if writeErr != nil {
+		out.Close()
+		return count, writeErr
+	}
+
+	if err != nil {
+		out.Close()
+		return count, queryError(ctx, timeout, err)
+	}
+
+	return count, out.Close()
+}
+
+// Load an archive written by Export, or any document in one of
+// PBGArchiveFormats, into the graph. Quads are written in chunks as they are
+// read and those already present are skipped, so archives can be loaded over
+// an existing database. Returns the number of quads read.
+func (pbg *ProgramBehaviorGraph) Import(r io.Reader, format string) (int, error) {
+	in, err := newArchiveReader(r, format)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer in.Close()
+
+	qw, err := writer.NewSingle(pbg.store.QuadStore, graph.IgnoreOpts{ IgnoreDup: true })
+
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel, timeout := pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	chunk := make([]quad.Quad, 0, importChunkSize)
+	count := 0
+
+	flush := func() error {
+		pbg.writeMu.Lock()
+		defer pbg.writeMu.Unlock()
+
+		err := qw.AddQuadSet(chunk)
+		chunk = chunk[:0]
+
+		return err
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return count, queryError(ctx, timeout, ctx.Err())
+		}
+
+		q, err := in.ReadQuad()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return count, fmt.Errorf("quad %d: %v", count + 1, err)
+		}
+
+		chunk = append(chunk, q)
+		count += 1
+
+		if len(chunk) == importChunkSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Context every query and traversal of the graph runs under unless its
+// options carry their own. Cancelling it stops whatever is running.
+func (pbg *ProgramBehaviorGraph) SetContext(ctx context.Context) {
+	pbg.ctx = ctx
+}
+
+// Deadline applied to queries that don't set a timeout. Zero or less means
+// queries may run indefinitely.
+func (pbg *ProgramBehaviorGraph) SetQueryTimeout(timeout time.Duration) {
+	pbg.queryTimeout = timeout
+}
+
+// Context for one query along with the timeout it was given, zero if none
+func (pbg *ProgramBehaviorGraph) queryContext(opts PBGQueryOptions) (context.Context, context.CancelFunc, time.Duration) {
+	ctx := opts.Context
+
+	if ctx == nil {
+		ctx = pbg.ctx
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	timeout := opts.Timeout
+
+	if timeout == 0 {
+		timeout = pbg.queryTimeout
+	}
+
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, 0
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// Explain why a query stopped early, or pass its error through
+func queryError(ctx context.Context, timeout time.Duration, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("query timed out after %s", timeout)
+	case context.Canceled:
+		return fmt.Errorf("query cancelled")
+	}
+
+	return err
+}
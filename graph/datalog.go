@@ -3,9 +3,17 @@ package graph;
 import (
 	"fmt"
 	"bufio"
+	"encoding/json"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"strconv"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+	"pbg/datalog"
 )
 
 type DestFile struct {
@@ -13,52 +21,575 @@ type DestFile struct {
 	Writer *bufio.Writer
 };
 
+// What GenerateDatalog exports
+type PBGDatalogOptions struct {
+	// Runs whose facts are exported along with static ones, every quad if
+	// none are given
+	Runs []string
+
+	// Predicates to export, all of them if empty, and predicates to leave
+	// out. Relation names with underscores are accepted too.
+	Predicates []string
+	Exclude []string
+}
+
+// A relation written by GenerateDatalog
+type PBGDatalogRelation struct {
+	Relation string `json:"relation"`
+	Predicate string `json:"predicate"`
+	File string `json:"file"`
+	Rows int `json:"rows"`
+}
+
+// Description of an export, written to manifest.json next to the facts
+type PBGDatalogManifest struct {
+	Runs []string `json:"runs"`
+	Relations []PBGDatalogRelation `json:"relations"`
+}
+
+// Rewrite hex numbers such as 0x401000 or 0X401000 to decimal, which is all
+// Souffle reads. Numbers at or above 2^63 become negative, the way Addr.Value
+// stores them, so an address reads the same whether it was kept as a number
+// or as text.
 func rewriteNumber(potentialNumber string) string {
-	if len(potentialNumber) >= 2 && potentialNumber[:2] == "0x" {
+	if len(potentialNumber) > 2 && (potentialNumber[:2] == "0x" || potentialNumber[:2] == "0X") {
 		foundNumber, err := strconv.ParseUint(potentialNumber[2:], 16, 64)
 
 		if err != nil {
 			return potentialNumber
 		}
 		
-		return strconv.FormatUint(foundNumber, 10)
+		return strconv.FormatInt(int64(foundNumber), 10)
 	} else {
 		return potentialNumber
 	}
 }
 
-func getFilePred(filename string, pred string, files map[string] DestFile) *bufio.Writer {
-	if obj, ok := files[pred]; ok {
-		return obj.Writer
+// Spell a value of a number column. Addresses are already numeric, while
+// strings still get a chance to be rewritten from hex. Symbol columns keep
+// the text of their values as it is.
+func datalogValue(v quad.Value) string {
+	if _, ok := v.(quad.Int); ok {
+		return nativeString(v)
+	}
+
+	return rewriteNumber(nativeString(v))
+}
+
+// Whether a value can be read as a Souffle number
+func datalogNumeric(v quad.Value) bool {
+	if _, ok := v.(quad.Int); ok {
+		return true
+	}
+
+	_, err := strconv.ParseInt(datalogValue(v), 10, 64)
+
+	return err == nil
+}
+
+// Name of the relation a predicate is exported as
+func datalogRelationName(predicate string) string {
+	return strings.ReplaceAll(predicate, "-", "_")
+}
+
+// A relation being exported, along with what its facts looked like to
+// declare it in schema.dl
+type datalogRelation struct {
+	predicate string
+	dest DestFile
+	rows int
+	subjectNumeric bool
+	objectNumeric bool
+
+	// Whether a column holds hex text, only spelled in decimal once the
+	// column turns out to be exported as a number
+	subjectHex bool
+	objectHex bool
+
+	// Column types declared in schema.dl
+	subjectType string
+	objectType string
+
+	// Whether every row has a run column
+	scoped bool
+}
+
+// Column type of a relation. Declared kinds win unless the data disagrees,
+// undeclared ones are inferred from the data.
+func (pbg *ProgramBehaviorGraph) datalogColumnType(rel *datalogRelation, column string, declared PBGValueKind, numeric bool) string {
+	switch {
+	case declared == ValueSymbol:
+		return "symbol"
+	case declared == ValueNumber && !numeric:
+		pbg.logger.Printf("%s of %s is declared numeric but isn't, exporting it as a symbol\n", column, rel.predicate)
+		return "symbol"
+	case numeric:
+		return "number"
+	}
+
+	return "symbol"
+}
+
+// Settle the column types of an exported relation
+func (pbg *ProgramBehaviorGraph) typeDatalogRelation(rel *datalogRelation, declared PBGPredicateType) {
+	subjectNumeric, objectNumeric := rel.subjectNumeric, rel.objectNumeric
+
+	// Relations without facts have nothing to contradict their declaration
+	if rel.rows == 0 {
+		subjectNumeric = declared.Subject == ValueNumber
+		objectNumeric = declared.Object == ValueNumber
 	}
 
-	file, err := os.OpenFile(filename + "/" + pred + ".facts", os.O_RDWR|os.O_CREATE, 0755)
+	rel.subjectType = pbg.datalogColumnType(rel, "subject", declared.Subject, subjectNumeric)
+	rel.objectType = pbg.datalogColumnType(rel, "object", declared.Object, objectNumeric)
+}
+
+// Spell the hex numbers of some columns of a fact file in decimal
+func rewriteFactColumns(path string, columns []bool) error {
+	in, err := os.Open(path)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
+	defer in.Close()
+
+	out, err := os.Create(path + ".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+
+	for {
+		line, readErr := reader.ReadString('\n')
+
+		if line != "" {
+			fields := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+
+			for i, rewrite := range columns {
+				if rewrite && i < len(fields) {
+					fields[i] = rewriteNumber(fields[i])
+				}
+			}
+
+			writer.WriteString(strings.Join(fields, "\t") + "\n")
+		}
+
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			out.Close()
+			return readErr
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path + ".tmp", path)
+}
+
+// Write schema.dl declaring every exported relation as an input
+func (pbg *ProgramBehaviorGraph) writeDatalogSchema(dir string, names []string, relations map[string] *datalogRelation) error {
+	file, err := os.Create(filepath.Join(dir, "schema.dl"))
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
 	writer := bufio.NewWriter(file)
-	files[pred] = DestFile{ file, writer }
 
-	return writer
+	writer.WriteString("// Relations exported by pbg, one fact file each. Backslashes, tabs and\n")
+	writer.WriteString("// line breaks in symbols are escaped as \\\\, \\t and \\n.\n\n")
+
+	for _, name := range names {
+		rel := relations[name]
+		subject, object := rel.subjectType, rel.objectType
+
+		if rel.scoped {
+			writer.WriteString(fmt.Sprintf(".decl %s(subject: %s, object: %s, run: symbol)\n", name, subject, object))
+		} else {
+			writer.WriteString(fmt.Sprintf(".decl %s(subject: %s, object: %s)\n", name, subject, object))
+		}
+
+		writer.WriteString(fmt.Sprintf(".input %s\n", name))
+	}
+
+	return writer.Flush()
 }
 
-func (pbg *ProgramBehaviorGraph) GenerateDatalog(filename string) {
-	ch := pbg.QueryTripletAsync("g.V().Tag('subject').Out(null, 'predicate').Tag('object').All()")
+// Write manifest.json listing the exported relations and their row counts
+func writeDatalogManifest(dir string, manifest *PBGDatalogManifest) error {
+	file, err := os.Create(filepath.Join(dir, "manifest.json"))
 
-	files := make(map[string] DestFile)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(manifest)
+}
+
+// Open the fact file of a relation, replacing whatever an earlier export left.
+// Rows of scoped relations name their run in a third column.
+func openRelation(dir string, name string, predicate string, scoped bool) (*datalogRelation, error) {
+	file, err := os.Create(filepath.Join(dir, name + ".facts"))
 
-	for triplet := range ch {
-		pred := strings.ReplaceAll(triplet.predicate[1:len(triplet.predicate)-1], "-", "_")
-		writer := getFilePred(filename, pred, files)
-		subj := triplet.subject[1:len(triplet.subject)-1]
-		obj := triplet.object[1:len(triplet.object)-1]
-		writer.WriteString(fmt.Sprintf("%s\t%s\n", rewriteNumber(subj), rewriteNumber(obj)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &datalogRelation{
+		predicate: predicate,
+		dest: DestFile{ file, bufio.NewWriter(file) },
+		subjectNumeric: true,
+		objectNumeric: true,
+		scoped: scoped,
+	}, nil
+}
+
+// Run a quad label belongs to among the selected ones. Static quads and, when
+// no runs are selected, every quad are kept with an empty run.
+func selectRun(label quad.Value, runs []string) (string, bool) {
+	if len(runs) == 0 || !isRunLabel(label) {
+		return "", true
 	}
 
-	for _, obj := range files {
-		obj.Writer.Flush()
-		obj.File.Close()
+	for _, selected := range runs {
+		if inRun(label, selected) {
+			return selected, true
+		}
 	}
-}
\ No newline at end of file
+
+	return "", false
+}
+
+// Write a fact file per predicate into the given directory, along with a
+// schema.dl declaring them and a manifest.json counting their rows. Symbols
+// are escaped with datalog.EscapeSymbol, hex text is only spelled in decimal
+// in columns declared as numbers, and files of an earlier export are
+// overwritten.
+//
+// With no runs every quad is exported; otherwise only static quads and those
+// of the listed runs are. When several runs are selected, every fact gets a
+// third column naming its run so executions can be compared, left empty for
+// static facts.
+func (pbg *ProgramBehaviorGraph) GenerateDatalog(dir string, opts PBGDatalogOptions) (*PBGDatalogManifest, error) {
+	ctx, cancel, timeout := pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	relations := make(map[string] *datalogRelation)
+	scoped := len(opts.Runs) > 1
+	excluded := make(map[string] bool)
+	var writeErr error
+
+	for _, predicate := range opts.Exclude {
+		excluded[datalogRelationName(predicate)] = true
+	}
+
+	// Every requested relation gets a file, even without facts, so Souffle
+	// finds all of its inputs
+	requested := make([]string, 0, len(opts.Predicates))
+
+	for _, predicate := range opts.Predicates {
+		name := datalogRelationName(predicate)
+
+		if excluded[name] || relations[name] != nil {
+			continue
+		}
+
+		rel, err := openRelation(dir, name, strings.ReplaceAll(predicate, "_", "-"), scoped)
+
+		if err != nil {
+			writeErr = err
+			break
+		}
+
+		relations[name] = rel
+		requested = append(requested, name)
+	}
+
+	export := func(ref graph.Ref) {
+		// Nothing more gets written once a file failed
+		if writeErr != nil {
+			return
+		}
+
+		triplet := pbg.store.Quad(ref)
+		run, ok := selectRun(triplet.Label, opts.Runs)
+
+		if !ok {
+			return
+		}
+
+		name := datalogRelationName(nativeString(triplet.Predicate))
+
+		if excluded[name] {
+			return
+		}
+
+		rel, ok := relations[name]
+
+		if !ok {
+			var err error
+			rel, err = openRelation(dir, name, nativeString(triplet.Predicate), scoped)
+
+			if err != nil {
+				writeErr = err
+				return
+			}
+
+			relations[name] = rel
+		} else if rel.rows == 0 {
+			// Requested predicates are named after the first spelling found
+			rel.predicate = nativeString(triplet.Predicate)
+		}
+
+		subject, object := nativeString(triplet.Subject), nativeString(triplet.Object)
+
+		rel.subjectNumeric = rel.subjectNumeric && datalogNumeric(triplet.Subject)
+		rel.objectNumeric = rel.objectNumeric && datalogNumeric(triplet.Object)
+		rel.subjectHex = rel.subjectHex || datalogValue(triplet.Subject) != subject
+		rel.objectHex = rel.objectHex || datalogValue(triplet.Object) != object
+		rel.rows += 1
+
+		// Values are written as they are, number columns are spelled in
+		// decimal once the relation is complete
+		columns := []string{ datalog.EscapeSymbol(subject), datalog.EscapeSymbol(object) }
+
+		if rel.scoped {
+			columns = append(columns, datalog.EscapeSymbol(run))
+		}
+
+		rel.dest.Writer.WriteString(strings.Join(columns, "\t") + "\n")
+	}
+
+	var err error
+
+	if len(opts.Predicates) == 0 {
+		it := pbg.store.QuadsAllIterator()
+		err = graph.Iterate(ctx, it).Each(export)
+		it.Close()
+	} else {
+		for _, name := range requested {
+			for _, spelling := range relationPredicates(name) {
+				ref := pbg.store.ValueOf(quad.String(spelling))
+
+				if ref == nil || err != nil {
+					continue
+				}
+
+				it := pbg.store.QuadIterator(quad.Predicate, ref)
+				err = graph.Iterate(ctx, it).Each(export)
+				it.Close()
+			}
+		}
+	}
+
+	names := make([]string, 0, len(relations))
+
+	for name, rel := range relations {
+		names = append(names, name)
+
+		if flushErr := rel.dest.Writer.Flush(); flushErr != nil && writeErr == nil {
+			writeErr = flushErr
+		}
+
+		rel.dest.File.Close()
+	}
+
+	if err != nil {
+		return nil, queryError(ctx, timeout, err)
+	}
+
+	if writeErr != nil {
+		return nil, writeErr
+	}
+
+	sort.Strings(names)
+
+	types := PredicateTypes()
+
+	for _, name := range names {
+		rel := relations[name]
+		pbg.typeDatalogRelation(rel, types[rel.predicate])
+
+		rewrite := []bool{
+			rel.subjectHex && rel.subjectType == "number",
+			rel.objectHex && rel.objectType == "number",
+		}
+
+		if rewrite[0] || rewrite[1] {
+			if err := rewriteFactColumns(filepath.Join(dir, name + ".facts"), rewrite); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	manifest := &PBGDatalogManifest{ Runs: opts.Runs, Relations: make([]PBGDatalogRelation, 0, len(names)) }
+
+	if manifest.Runs == nil {
+		manifest.Runs = []string{}
+	}
+
+	for _, name := range names {
+		rel := relations[name]
+
+		manifest.Relations = append(manifest.Relations, PBGDatalogRelation{
+			Relation: name,
+			Predicate: rel.predicate,
+			File: name + ".facts",
+			Rows: rel.rows,
+		})
+	}
+
+	if err := pbg.writeDatalogSchema(dir, names, relations); err != nil {
+		return nil, err
+	}
+
+	if err := writeDatalogManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Predicates an input relation may have been exported from. Dashes become
+// underscores in relation names, so both spellings are tried.
+func relationPredicates(rel string) []string {
+	predicates := []string{ strings.ReplaceAll(rel, "_", "-") }
+
+	if predicates[0] != rel {
+		predicates = append(predicates, rel)
+	}
+
+	return predicates
+}
+
+// Convert a graph value to a Datalog column, the same way fact files spell it
+func datalogColumn(v quad.Value, column datalog.Column) (datalog.Value, error) {
+	if column.Type == datalog.SymbolColumn {
+		return nativeString(v), nil
+	}
+
+	text := datalogValue(v)
+
+	if i, ok := v.(quad.Int); ok {
+		return int64(i), nil
+	}
+
+	n, err := strconv.ParseInt(text, 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s isn't a number", quad.StringOf(v))
+	}
+
+	return n, nil
+}
+
+// Load the input relations of a Datalog program straight from the graph, the
+// same facts GenerateDatalog would write, and evaluate it. Inputs have a
+// subject and an object column, plus a run column when several runs are
+// selected, which is empty for static facts.
+func (pbg *ProgramBehaviorGraph) EvaluateDatalog(program *datalog.Program, runs ...string) (*datalog.Database, error) {
+	ctx, cancel, timeout := pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	db := datalog.NewDatabase(program)
+
+	for _, rel := range program.Inputs {
+		columns := program.Decls[rel].Columns
+
+		if len(columns) != 2 && (len(columns) != 3 || len(runs) < 2) {
+			return nil, fmt.Errorf("input %s should have two columns, or three with several runs", rel)
+		}
+
+		count := 0
+
+		for _, predicate := range relationPredicates(rel) {
+			ref := pbg.store.ValueOf(quad.String(predicate))
+
+			if ref == nil {
+				continue
+			}
+
+			var loadErr error
+
+			it := pbg.store.QuadIterator(quad.Predicate, ref)
+
+			err := graph.Iterate(ctx, it).Each(func(ref graph.Ref) {
+				if loadErr != nil {
+					return
+				}
+
+				q := pbg.store.Quad(ref)
+				run, ok := selectRun(q.Label, runs)
+
+				if !ok {
+					return
+				}
+
+				tuple := make([]datalog.Value, 0, len(columns))
+
+				for i, v := range []quad.Value{ q.Subject, q.Object } {
+					value, err := datalogColumn(v, columns[i])
+
+					if err != nil {
+						loadErr = fmt.Errorf("column %s of %s: %v", columns[i].Name, rel, err)
+						return
+					}
+
+					tuple = append(tuple, value)
+				}
+
+				if len(columns) == 3 {
+					tuple = append(tuple, run)
+				}
+
+				if err := db.Add(rel, tuple); err != nil {
+					loadErr = err
+					return
+				}
+
+				count += 1
+			})
+
+			it.Close()
+
+			if err != nil {
+				return nil, queryError(ctx, timeout, err)
+			}
+
+			if loadErr != nil {
+				return nil, loadErr
+			}
+		}
+
+		pbg.logger.Printf("Loaded %d facts into %s\n", count, rel)
+	}
+
+	if err := db.Evaluate(ctx); err != nil {
+		return nil, queryError(ctx, timeout, err)
+	}
+
+	return db, nil
+}
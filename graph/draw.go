@@ -1,43 +1,512 @@
 package graph;
 
 import (
-	"github.com/emicklei/dot"
-	"os"
 	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/dot"
+	"github.com/cayleygraph/cayley/quad"
 )
 
-func (pbg *ProgramBehaviorGraph) Draw(query string, filename string) {
-	out, err := pbg.QueryTriplet(query)
+// Formats Draw can write, picked from the file extension unless asked for
+var PBGDrawFormats = []string{ "dot", "mermaid", "graphml", "gexf", "html" }
 
-	if err != nil {
-		panic(err)
+// Ways nodes can be grouped when drawing, after the kind of entity their
+// identity is scoped under
+var PBGDrawClusters = map[string] string {
+	"function": "func",
+	"cu": "cu",
+	"section": "section",
+}
+
+// How a graph is drawn. The zero value draws every triplet as it is.
+type PBGDrawOptions struct {
+	// One of PBGDrawFormats, guessed from the file name when empty
+	Format string
+
+	// Group nodes by the function, compile unit or section they belong to,
+	// see PBGDrawClusters. Addresses are placed in the section holding them.
+	Cluster string
+
+	// Colour edges by the family of their predicate, such as DWARF types,
+	// traces or source files
+	Color bool
+
+	// Chains of next-step edges longer than this are drawn as a single edge,
+	// 0 keeps every step
+	CollapseSteps int
+
+	// Nodes drawn at most, in the order the triplets reach them; 0 for no
+	// limit
+	MaxNodes int
+
+	// Graphviz page size in inches, such as 7.75,10.25, none if empty
+	Size string
+}
+
+// Families of predicates coloured alike. Predicates of run-scoped providers
+// not listed here are traces.
+var predicateFamilies = map[string] string {
+	"has-cu": "dwarf", "defined-in": "dwarf", "has-var": "dwarf", "has-global-var": "dwarf",
+	"has-param": "dwarf", "has-var-type": "dwarf", "runtime-at": "dwarf", "has-type-name": "dwarf",
+	"has-member": "dwarf", "has-member-name": "dwarf", "has-member-type": "dwarf",
+	"has-data-offset": "dwarf", "has-real-type": "dwarf", "const-type": "dwarf",
+	"restrict-type": "dwarf", "pointer-type": "dwarf", "array-type": "dwarf",
+	"has-subrange": "dwarf", "subrange-type": "dwarf", "enumerator-type": "dwarf",
+	"has-enumerator": "dwarf", "enumeration-type": "dwarf", "subroutine-type": "dwarf",
+	"decl-at": "source", "text-at-pc": "source", "contains-file": "source", "has-path": "source",
+	"has-text": "source", "has-line": "source", "line-content": "source",
+	"prog-entry-point": "binary", "has-section": "binary", "elf-section-size": "binary",
+	"elf-section-addr": "binary", "section-has-data": "binary", "has-insn": "binary",
+	"at-address": "binary", "disassembles-to": "binary",
+}
+
+var familyColors = map[string] string {
+	"dwarf": "#1f77b4",
+	"trace": "#d62728",
+	"source": "#2ca02c",
+	"binary": "#ff7f0e",
+	"other": "#7f7f7f",
+}
+
+// Guess the drawing format from a file name, DOT if the extension doesn't
+// tell
+func drawFormatOf(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".mmd", ".mermaid":
+		return "mermaid"
+	case ".graphml":
+		return "graphml"
+	case ".gexf":
+		return "gexf"
+	case ".html", ".htm":
+		return "html"
+	}
+
+	return "dot"
+}
+
+type drawNode struct {
+	value quad.Value
+	label string
+	cluster int
+}
+
+type drawEdge struct {
+	from int
+	to int
+	label string
+	color string
+}
+
+// Nodes and edges ready to be written, with clusters numbered from 0 and
+// nodes outside of any cluster at -1
+type drawing struct {
+	nodes []drawNode
+	edges []drawEdge
+	clusters []string
+}
+
+// An edge of the drawing before nodes are numbered
+type drawTriplet struct {
+	subject quad.Value
+	predicate string
+	object quad.Value
+	label string
+}
+
+// Replace runs of next-step edges through steps that have nothing else drawn
+// by a single edge counting them, when longer than min
+func collapseSteps(triplets []drawTriplet, min int) []drawTriplet {
+	const step = "next-step"
+
+	degree := make(map[string] int)
+	in := make(map[string] int)
+	out := make(map[string] int)
+	next := make(map[string] int)
+
+	for i, t := range triplets {
+		subject, object := FormatValue(t.subject), FormatValue(t.object)
+		degree[subject] += 1
+		degree[object] += 1
+
+		if t.predicate == step {
+			out[subject] += 1
+			in[object] += 1
+			next[subject] = i
+		}
+	}
+
+	interior := func(v quad.Value) bool {
+		key := FormatValue(v)
+		return in[key] == 1 && out[key] == 1 && degree[key] == 2
+	}
+
+	result := make([]drawTriplet, 0, len(triplets))
+	handled := make([]bool, len(triplets))
+
+	for i, t := range triplets {
+		if t.predicate != step || interior(t.subject) {
+			continue
+		}
+
+		chain := []int{ i }
+		end := t.object
+
+		for interior(end) && len(chain) <= len(triplets) {
+			j := next[FormatValue(end)]
+			chain = append(chain, j)
+			end = triplets[j].object
+		}
+
+		for _, j := range chain {
+			handled[j] = true
+		}
+
+		if len(chain) > min {
+			result = append(result, drawTriplet{ t.subject, step, end, quad.StringOf(quad.String(fmt.Sprintf("%s x%d", step, len(chain)))) })
+		} else {
+			for _, j := range chain {
+				result = append(result, triplets[j])
+			}
+		}
+	}
+
+	// Everything else, including steps going round in a cycle, is kept
+	for i, t := range triplets {
+		if !handled[i] {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// Cluster an entity falls in given the kind of scope, named after the scope
+func scopeCluster(v quad.Value, kind string) (string, string, bool) {
+	iri, ok := v.(quad.IRI)
+
+	if !ok {
+		return "", "", false
 	}
 
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0755)
+	id := string(iri)
+	marker := "/" + kind + "/"
+	start := strings.LastIndex(id, marker)
+
+	if start < 0 {
+		return "", "", false
+	}
+
+	name := id[start + len(marker):]
+
+	if end := strings.Index(name, "/"); end >= 0 {
+		name = name[:end]
+	}
+
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+
+	return id[:start + len(marker) + len(name)], kind + " " + name, true
+}
+
+// A section and the addresses it covers
+type sectionRange struct {
+	id quad.Value
+	start Addr
+	end Addr
+}
+
+func (pbg *ProgramBehaviorGraph) sectionRanges() ([]sectionRange, error) {
+	tagged, err := pbg.V().In("elf-section-addr").Tag("section").
+		Out("elf-section-addr").Tag("addr").Back("section").
+		Out("elf-section-size").Tag("size").Tagged()
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	defer file.Close()
-	writer := bufio.NewWriter(file)
+	ranges := make([]sectionRange, 0, len(tagged))
+
+	for _, tags := range tagged {
+		start, okStart := AddrOf(tags["addr"])
+		size, okSize := AddrOf(tags["size"])
+
+		if okStart && okSize && size > 0 {
+			ranges = append(ranges, sectionRange{ tags["section"], start, start + size })
+		}
+	}
+
+	return ranges, nil
+}
+
+// Lay triplets out as a drawing according to the options
+func (pbg *ProgramBehaviorGraph) layoutDrawing(triplets []PBGTriplet, opts PBGDrawOptions) (*drawing, error) {
+	edges := make([]drawTriplet, 0, len(triplets))
+
+	for _, t := range triplets {
+		predicate := nativeString(t.predicate)
+		edges = append(edges, drawTriplet{ t.subject, predicate, t.object, quad.StringOf(t.predicate) })
+	}
+
+	if opts.CollapseSteps > 0 {
+		edges = collapseSteps(edges, opts.CollapseSteps)
+	}
+
+	kind, ok := PBGDrawClusters[opts.Cluster]
+
+	if opts.Cluster != "" && !ok {
+		return nil, fmt.Errorf("unknown cluster %s, expected function, cu or section", opts.Cluster)
+	}
+
+	var sections []sectionRange
+
+	if opts.Cluster == "section" {
+		var err error
+
+		if sections, err = pbg.sectionRanges(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Predicates of run-scoped providers are traces unless listed
+	traced := make(map[string] bool)
+
+	for _, spec := range ProviderSpecs() {
+		for _, predicate := range spec.Produces {
+			traced[predicate] = traced[predicate] || spec.RunScoped
+		}
+	}
+
+	d := &drawing{}
+	nodes := make(map[string] int)
+	clusters := make(map[string] int)
+	dropped := 0
 
+	cluster := func(v quad.Value) int {
+		if kind == "" {
+			return -1
+		}
+
+		id, label, ok := scopeCluster(v, kind)
+
+		if !ok && kind == "section" {
+			if addr, isAddr := v.(quad.Int); isAddr {
+				for _, section := range sections {
+					if Addr(addr) >= section.start && Addr(addr) < section.end {
+						id, label, ok = scopeCluster(section.id, kind)
+						break
+					}
+				}
+			}
+		}
+
+		if !ok {
+			return -1
+		}
+
+		if index, seen := clusters[id]; seen {
+			return index
+		}
+
+		clusters[id] = len(d.clusters)
+		d.clusters = append(d.clusters, label)
+
+		return clusters[id]
+	}
+
+	node := func(v quad.Value) int {
+		key := FormatValue(v)
+
+		if index, ok := nodes[key]; ok {
+			return index
+		}
+
+		nodes[key] = len(d.nodes)
+		d.nodes = append(d.nodes, drawNode{ v, quad.StringOf(v), cluster(v) })
+
+		return nodes[key]
+	}
+
+	for _, t := range edges {
+		// Edges are only drawn when both of their ends fit
+		if opts.MaxNodes > 0 {
+			missing := make(map[string] bool)
+
+			for _, v := range []quad.Value{ t.subject, t.object } {
+				if _, ok := nodes[FormatValue(v)]; !ok {
+					missing[FormatValue(v)] = true
+				}
+			}
+
+			if len(d.nodes) + len(missing) > opts.MaxNodes {
+				dropped += 1
+				continue
+			}
+		}
+
+		edge := drawEdge{ from: node(t.subject), to: node(t.object), label: t.label }
+
+		if opts.Color {
+			family, ok := predicateFamilies[t.predicate]
+
+			if !ok && traced[t.predicate] {
+				family = "trace"
+			} else if !ok {
+				family = "other"
+			}
+
+			edge.color = familyColors[family]
+		}
+
+		d.edges = append(d.edges, edge)
+	}
+
+	if dropped > 0 {
+		pbg.logger.Printf("Dropped %d edges to stay within %d nodes\n", dropped, opts.MaxNodes)
+	}
+
+	return d, nil
+}
+
+func writeDOT(w io.Writer, d *drawing, opts PBGDrawOptions) error {
 	g := dot.NewGraph(dot.Directed)
-	g.Attr("size", "7.75,10.25")
+
+	if opts.Size != "" {
+		g.Attr("size", opts.Size)
+	}
+
 	g.Attr("ratio", "compress")
-	nodes := make(map[string] dot.Node, 0)
 
-	for _, item := range out {
-		if _, ok := nodes[item.subject]; !ok {
-			nodes[item.subject] = g.Node(item.subject)
+	subgraphs := make([]*dot.Graph, len(d.clusters))
+
+	for i, label := range d.clusters {
+		subgraphs[i] = g.Subgraph(label, dot.ClusterOption{})
+	}
+
+	nodes := make([]dot.Node, len(d.nodes))
+
+	for i, n := range d.nodes {
+		if n.cluster >= 0 {
+			nodes[i] = subgraphs[n.cluster].Node(n.label)
+		} else {
+			nodes[i] = g.Node(n.label)
 		}
+	}
+
+	for _, e := range d.edges {
+		edge := g.Edge(nodes[e.from], nodes[e.to], e.label)
 
-		if _, ok := nodes[item.object]; !ok {
-			nodes[item.object] = g.Node(item.object)
+		if e.color != "" {
+			edge.Attr("color", e.color)
+			edge.Attr("fontcolor", e.color)
 		}
+	}
+
+	_, err := io.WriteString(w, g.String())
+
+	return err
+}
+
+// Write a drawing in the requested format
+func writeDrawing(w io.Writer, d *drawing, opts PBGDrawOptions) error {
+	switch opts.Format {
+	case "dot":
+		return writeDOT(w, d, opts)
+	case "mermaid":
+		return writeMermaid(w, d)
+	case "graphml":
+		return writeDrawingGraphML(w, d)
+	case "gexf":
+		return writeGEXF(w, d)
+	case "html":
+		return writeDrawingHTML(w, d)
+	}
+
+	return fmt.Errorf("unknown drawing format %s, expected one of %s", opts.Format, strings.Join(PBGDrawFormats, ", "))
+}
+
+// Draw triplets into a file
+func (pbg *ProgramBehaviorGraph) DrawTriplets(triplets []PBGTriplet, filename string, opts PBGDrawOptions) error {
+	if opts.Format == "" {
+		opts.Format = drawFormatOf(filename)
+	}
+
+	d, err := pbg.layoutDrawing(triplets, opts)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	if err := writeDrawing(writer, d, opts); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+// Draw the triplets of a query tagging subject, predicate and object
+func (pbg *ProgramBehaviorGraph) DrawWith(query string, filename string, opts PBGDrawOptions) error {
+	out, err := pbg.QueryTriplet(query)
+
+	if err != nil {
+		return err
+	}
+
+	return pbg.DrawTriplets(out, filename, opts)
+}
+
+// Draw the triplets of a query as a Graphviz graph sized for a page
+func (pbg *ProgramBehaviorGraph) Draw(query string, filename string) error {
+	return pbg.DrawWith(query, filename, PBGDrawOptions{ Format: "dot", Size: "7.75,10.25" })
+}
+
+// Clusters in order with the nodes they hold, for formats that nest them
+func (d *drawing) clusterMembers() [][]int {
+	members := make([][]int, len(d.clusters))
 
-		nodes[item.subject].Edge(nodes[item.object], item.predicate)
+	for i, n := range d.nodes {
+		if n.cluster >= 0 {
+			members[n.cluster] = append(members[n.cluster], i)
+		}
+	}
+
+	return members
+}
+
+// Families coloured in a drawing, for legends
+func (d *drawing) colors() []string {
+	used := make(map[string] bool)
+
+	for _, e := range d.edges {
+		used[e.color] = true
 	}
 
-	writer.WriteString(g.String())
-}
\ No newline at end of file
+	families := make([]string, 0)
+
+	for family, color := range familyColors {
+		if used[color] {
+			families = append(families, family)
+		}
+	}
+
+	sort.Strings(families)
+
+	return families
+}
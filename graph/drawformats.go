@@ -0,0 +1,293 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+func writeMermaid(w io.Writer, d *drawing) error {
+	out := bufio.NewWriter(w)
+	out.WriteString("flowchart LR\n")
+
+	node := func(indent string, i int) {
+		fmt.Fprintf(out, "%sn%d[\"%s\"]\n", indent, i, mermaidEscaper.Replace(d.nodes[i].label))
+	}
+
+	for c, members := range d.clusterMembers() {
+		fmt.Fprintf(out, "  subgraph c%d [\"%s\"]\n", c, mermaidEscaper.Replace(d.clusters[c]))
+
+		for _, i := range members {
+			node("    ", i)
+		}
+
+		out.WriteString("  end\n")
+	}
+
+	for i, n := range d.nodes {
+		if n.cluster < 0 {
+			node("  ", i)
+		}
+	}
+
+	for _, e := range d.edges {
+		fmt.Fprintf(out, "  n%d -->|\"%s\"| n%d\n", e.from, mermaidEscaper.Replace(e.label), e.to)
+	}
+
+	for i, e := range d.edges {
+		if e.color != "" {
+			fmt.Fprintf(out, "  linkStyle %d stroke:%s\n", i, e.color)
+		}
+	}
+
+	return out.Flush()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
+
+func writeDrawingGraphML(w io.Writer, d *drawing) error {
+	out := bufio.NewWriter(w)
+
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="label" for="node" attr.name="label" attr.type="string"/>
+  <key id="cluster" for="node" attr.name="cluster" attr.type="string"/>
+  <key id="predicate" for="edge" attr.name="label" attr.type="string"/>
+  <key id="color" for="edge" attr.name="color" attr.type="string"/>
+  <graph id="pbg" edgedefault="directed">
+`)
+
+	for i, n := range d.nodes {
+		fmt.Fprintf(out, `    <node id="n%d"><data key="label">%s</data>`, i, xmlEscape(n.label))
+
+		if n.cluster >= 0 {
+			fmt.Fprintf(out, `<data key="cluster">%s</data>`, xmlEscape(d.clusters[n.cluster]))
+		}
+
+		out.WriteString("</node>\n")
+	}
+
+	for _, e := range d.edges {
+		fmt.Fprintf(out, `    <edge source="n%d" target="n%d"><data key="predicate">%s</data>`, e.from, e.to, xmlEscape(e.label))
+
+		if e.color != "" {
+			fmt.Fprintf(out, `<data key="color">%s</data>`, e.color)
+		}
+
+		out.WriteString("</edge>\n")
+	}
+
+	out.WriteString("  </graph>\n</graphml>\n")
+
+	return out.Flush()
+}
+
+func writeGEXF(w io.Writer, d *drawing) error {
+	out := bufio.NewWriter(w)
+
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<gexf xmlns="http://gexf.net/1.2" xmlns:viz="http://gexf.net/1.2/viz" version="1.2">
+  <graph defaultedgetype="directed">
+    <attributes class="node">
+      <attribute id="cluster" title="cluster" type="string"/>
+    </attributes>
+    <nodes>
+`)
+
+	for i, n := range d.nodes {
+		fmt.Fprintf(out, `      <node id="n%d" label="%s">`, i, xmlEscape(n.label))
+
+		if n.cluster >= 0 {
+			fmt.Fprintf(out, `<attvalues><attvalue for="cluster" value="%s"/></attvalues>`, xmlEscape(d.clusters[n.cluster]))
+		}
+
+		out.WriteString("</node>\n")
+	}
+
+	out.WriteString("    </nodes>\n    <edges>\n")
+
+	for i, e := range d.edges {
+		fmt.Fprintf(out, `      <edge id="e%d" source="n%d" target="n%d" label="%s">`, i, e.from, e.to, xmlEscape(e.label))
+
+		// Colours are #rrggbb
+		if len(e.color) == 7 {
+			rgb, err := strconv.ParseUint(e.color[1:], 16, 32)
+
+			if err == nil {
+				fmt.Fprintf(out, `<viz:color r="%d" g="%d" b="%d"/>`, rgb >> 16, (rgb >> 8) & 0xff, rgb & 0xff)
+			}
+		}
+
+		out.WriteString("</edge>\n")
+	}
+
+	out.WriteString("    </edges>\n  </graph>\n</gexf>\n")
+
+	return out.Flush()
+}
+
+// A page drawing the graph by itself, without loading any scripts
+func writeDrawingHTML(w io.Writer, d *drawing) error {
+	type htmlNode struct {
+		Label string `json:"label"`
+		Cluster int `json:"cluster"`
+	}
+
+	type htmlEdge struct {
+		From int `json:"from"`
+		To int `json:"to"`
+		Label string `json:"label"`
+		Color string `json:"color,omitempty"`
+	}
+
+	type htmlFamily struct {
+		Name string `json:"name"`
+		Color string `json:"color"`
+	}
+
+	data := struct {
+		Nodes []htmlNode `json:"nodes"`
+		Edges []htmlEdge `json:"edges"`
+		Clusters []string `json:"clusters"`
+		Families []htmlFamily `json:"families"`
+	}{
+		Nodes: make([]htmlNode, 0, len(d.nodes)),
+		Edges: make([]htmlEdge, 0, len(d.edges)),
+		Clusters: append([]string{}, d.clusters...),
+		Families: make([]htmlFamily, 0),
+	}
+
+	for _, n := range d.nodes {
+		data.Nodes = append(data.Nodes, htmlNode{ n.label, n.cluster })
+	}
+
+	for _, e := range d.edges {
+		data.Edges = append(data.Edges, htmlEdge{ e.from, e.to, e.label, e.color })
+	}
+
+	for _, family := range d.colors() {
+		data.Families = append(data.Families, htmlFamily{ family, familyColors[family] })
+	}
+
+	// Marshalling escapes <, > and &, so the data can't end the script
+	encoded, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, strings.Replace(drawingPage, "/*DATA*/", string(encoded), 1))
+
+	return err
+}
+
+const drawingPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>pbg drawing</title>
+<style>
+body { margin: 0; font-family: sans-serif; }
+svg { width: 100vw; height: 100vh; display: block; }
+text { font-size: 10px; font-family: monospace; }
+#legend { position: fixed; top: 8px; left: 8px; background: rgba(255, 255, 255, 0.9); padding: 4px 8px; font-size: 12px; }
+</style>
+</head>
+<body>
+<div id="legend"></div>
+<svg id="graph"></svg>
+<script>
+var data = /*DATA*/;
+var palette = ["#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#bcbd22", "#17becf"];
+
+function el(tag, attrs, text) {
+  var e = document.createElementNS("http://www.w3.org/2000/svg", tag);
+  for (var k in attrs) e.setAttribute(k, attrs[k]);
+  if (text !== undefined) e.textContent = text;
+  return e;
+}
+
+function legend() {
+  var box = document.getElementById("legend");
+  data.families.forEach(function (f) {
+    var row = document.createElement("div");
+    row.style.color = f.color;
+    row.textContent = "— " + f.name;
+    box.appendChild(row);
+  });
+  data.clusters.forEach(function (c, i) {
+    var row = document.createElement("div");
+    row.style.color = palette[i % palette.length];
+    row.textContent = "● " + c;
+    box.appendChild(row);
+  });
+}
+
+// Lay the graph out with a simple force simulation, pulling nodes of a
+// cluster towards each other
+function draw() {
+  var svg = document.getElementById("graph");
+  var width = svg.clientWidth, height = svg.clientHeight;
+  var nodes = data.nodes.map(function (n, i) {
+    var angle = i * 2.4, r = 20 * Math.sqrt(i);
+    return { label: n.label, cluster: n.cluster, x: width / 2 + Math.cos(angle) * r, y: height / 2 + Math.sin(angle) * r };
+  });
+  var rounds = nodes.length > 500 ? 50 : 300;
+  for (var round = 0; round < rounds; round++) {
+    var centers = data.clusters.map(function () { return { x: 0, y: 0, n: 0 }; });
+    nodes.forEach(function (a) {
+      if (a.cluster >= 0) { centers[a.cluster].x += a.x; centers[a.cluster].y += a.y; centers[a.cluster].n++; }
+    });
+    nodes.forEach(function (a) {
+      a.dx = (width / 2 - a.x) * 0.005; a.dy = (height / 2 - a.y) * 0.005;
+      if (a.cluster >= 0) {
+        var c = centers[a.cluster];
+        a.dx += (c.x / c.n - a.x) * 0.05; a.dy += (c.y / c.n - a.y) * 0.05;
+      }
+      nodes.forEach(function (b) {
+        if (a === b) return;
+        var dx = a.x - b.x, dy = a.y - b.y, d2 = dx * dx + dy * dy + 0.01;
+        a.dx += dx * 400 / d2; a.dy += dy * 400 / d2;
+      });
+    });
+    data.edges.forEach(function (e) {
+      var s = nodes[e.from], t = nodes[e.to];
+      var dx = t.x - s.x, dy = t.y - s.y;
+      s.dx += dx * 0.02; s.dy += dy * 0.02;
+      t.dx -= dx * 0.02; t.dy -= dy * 0.02;
+    });
+    nodes.forEach(function (a) {
+      a.x = Math.max(20, Math.min(width - 20, a.x + a.dx));
+      a.y = Math.max(20, Math.min(height - 20, a.y + a.dy));
+    });
+  }
+  data.edges.forEach(function (e) {
+    var s = nodes[e.from], t = nodes[e.to], color = e.color || "#999";
+    svg.appendChild(el("line", { x1: s.x, y1: s.y, x2: t.x, y2: t.y, stroke: color }));
+    svg.appendChild(el("text", { x: (s.x + t.x) / 2, y: (s.y + t.y) / 2, fill: color }, e.label));
+  });
+  nodes.forEach(function (n) {
+    var circle = el("circle", { cx: n.x, cy: n.y, r: 5, fill: n.cluster >= 0 ? palette[n.cluster % palette.length] : "#555" });
+    circle.appendChild(el("title", {}, n.label));
+    svg.appendChild(circle);
+    svg.appendChild(el("text", { x: n.x + 7, y: n.y + 3 }, n.label.length > 40 ? n.label.slice(0, 40) + "..." : n.label));
+  });
+}
+
+legend();
+draw();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,310 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Render a value so that ParseValue reads it back: IRIs in angle brackets,
+// blank nodes prefixed with _:, strings quoted and integers and booleans as
+// they are.
+func FormatValue(v quad.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case quad.IRI:
+		return "<" + string(v) + ">"
+	case quad.String:
+		return strconv.Quote(string(v))
+	case quad.BNode:
+		return "_:" + string(v)
+	case quad.Int:
+		return strconv.FormatInt(int64(v), 10)
+	case quad.Bool:
+		return strconv.FormatBool(bool(v))
+	}
+
+	return quad.StringOf(v)
+}
+
+// Read a value written by FormatValue. Anything else is taken as a string,
+// so plain names can be typed as they are.
+func ParseValue(s string) quad.Value {
+	s = strings.TrimSpace(s)
+
+	if len(s) >= 2 && s[0] == '<' && s[len(s) - 1] == '>' {
+		return quad.IRI(s[1:len(s) - 1])
+	}
+
+	if len(s) >= 2 && s[0] == '"' {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			return quad.String(unquoted)
+		}
+	}
+
+	if len(s) > 2 && s[:2] == "_:" {
+		return quad.BNode(s[2:])
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return quad.Int(i)
+	}
+
+	if s == "true" || s == "false" {
+		return quad.Bool(s == "true")
+	}
+
+	return quad.String(s)
+}
+
+func (t PBGTriplet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string] string {
+		"subject": FormatValue(t.subject),
+		"predicate": FormatValue(t.predicate),
+		"object": FormatValue(t.object),
+	})
+}
+
+// Collect up to limit quads with the node in the given direction that pass
+// the filter, if any. A negative limit collects all of them. The context and
+// timeout come from queryContext.
+func (pbg *ProgramBehaviorGraph) edges(ctx context.Context, timeout time.Duration, node quad.Value, dir quad.Direction, limit int, keep func(quad.Quad) bool) ([]PBGTriplet, bool, error) {
+	// Cancelled on its own to stop at the limit
+	iterCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	edges := make([]PBGTriplet, 0)
+	truncated := false
+
+	ref := pbg.store.ValueOf(node)
+
+	if ref == nil {
+		return edges, false, nil
+	}
+
+	it := pbg.store.QuadIterator(dir, ref)
+	defer it.Close()
+
+	err := graph.Iterate(iterCtx, it).Each(func(ref graph.Ref) {
+		if truncated {
+			return
+		}
+
+		if limit >= 0 && len(edges) >= limit {
+			truncated = true
+			cancel()
+			return
+		}
+
+		q := pbg.store.Quad(ref)
+
+		if keep != nil && !keep(q) {
+			return
+		}
+
+		edges = append(edges, PBGTriplet { q.Subject, q.Predicate, q.Object })
+	})
+
+	if err != nil && !truncated {
+		return nil, false, queryError(ctx, timeout, err)
+	}
+
+	return edges, truncated, nil
+}
+
+// Edges leaving and entering a node, at most limit of each (negative for no
+// limit). The flag tells whether either list was cut short. Only the context
+// and timeout of the options apply.
+func (pbg *ProgramBehaviorGraph) Neighbors(node quad.Value, limit int, opts PBGQueryOptions) ([]PBGTriplet, []PBGTriplet, bool, error) {
+	ctx, cancel, timeout := pbg.queryContext(opts)
+	defer cancel()
+
+	out, outTruncated, err := pbg.edges(ctx, timeout, node, quad.Subject, limit, nil)
+
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	in, inTruncated, err := pbg.edges(ctx, timeout, node, quad.Object, limit, nil)
+
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return out, in, outTruncated || inTruncated, nil
+}
+
+// Which edges a neighborhood follows
+type PBGNeighborhoodOptions struct {
+	// Steps taken away from the centers, in either direction
+	Depth int
+
+	// Edges each node contributes per direction, so hubs such as a binary's
+	// instructions don't swamp the result; negative for no limit
+	Limit int
+
+	// Predicates followed, all of them if empty, and predicates never
+	// followed
+	Predicates []string
+	Exclude []string
+
+	// Expand addresses as well as entities, reaching for instance the
+	// instructions accessing a variable's location. Other literals are always
+	// leaves.
+	Addresses bool
+
+	// Context and timeout the whole walk runs under, the rest is ignored
+	Query PBGQueryOptions
+}
+
+// Every edge within depth steps of a node, in either direction. Each node
+// contributes at most limit edges per direction so hubs such as a binary's
+// instructions don't swamp the result.
+func (pbg *ProgramBehaviorGraph) Neighborhood(center quad.Value, depth int, limit int) ([]PBGTriplet, error) {
+	return pbg.NeighborhoodWith(PBGNeighborhoodOptions{ Depth: depth, Limit: limit }, center)
+}
+
+// Every edge the options allow within their depth of any of the centers
+func (pbg *ProgramBehaviorGraph) NeighborhoodWith(opts PBGNeighborhoodOptions, centers ...quad.Value) ([]PBGTriplet, error) {
+	ctx, cancel, timeout := pbg.queryContext(opts.Query)
+	defer cancel()
+
+	included := make(map[string] bool)
+	excluded := make(map[string] bool)
+
+	for _, predicate := range opts.Predicates {
+		included[predicate] = true
+	}
+
+	for _, predicate := range opts.Exclude {
+		excluded[predicate] = true
+	}
+
+	keep := func(q quad.Quad) bool {
+		predicate := nativeString(q.Predicate)
+		return (len(included) == 0 || included[predicate]) && !excluded[predicate]
+	}
+
+	visited := make(map[string] bool)
+	seen := make(map[string] bool)
+	frontier := make([]quad.Value, 0, len(centers))
+	edges := make([]PBGTriplet, 0)
+
+	for _, center := range centers {
+		if name := quad.StringOf(center); !visited[name] {
+			visited[name] = true
+			frontier = append(frontier, center)
+		}
+	}
+
+	for step := 0; step < opts.Depth && len(frontier) > 0; step++ {
+		next := make([]quad.Value, 0)
+
+		for _, node := range frontier {
+			out, _, err := pbg.edges(ctx, timeout, node, quad.Subject, opts.Limit, keep)
+
+			if err != nil {
+				return nil, err
+			}
+
+			in, _, err := pbg.edges(ctx, timeout, node, quad.Object, opts.Limit, keep)
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, edge := range append(out, in...) {
+				key := quad.StringOf(edge.subject) + " " + quad.StringOf(edge.predicate) + " " + quad.StringOf(edge.object)
+
+				if seen[key] {
+					continue
+				}
+
+				seen[key] = true
+				edges = append(edges, edge)
+
+				for _, end := range []quad.Value{ edge.subject, edge.object } {
+					switch end.(type) {
+					case quad.IRI:
+					case quad.Int:
+						if !opts.Addresses {
+							continue
+						}
+					default:
+						continue
+					}
+
+					if name := quad.StringOf(end); !visited[name] {
+						visited[name] = true
+						next = append(next, end)
+					}
+				}
+			}
+		}
+
+		frontier = next
+	}
+
+	return edges, nil
+}
+
+// Nodes a user typed, in the syntax of ParseValue. A plain string naming
+// entities stands for those entities rather than the name itself.
+func (pbg *ProgramBehaviorGraph) ResolveNodes(text string) ([]quad.Value, error) {
+	value := ParseValue(text)
+
+	if name, ok := value.(quad.String); ok {
+		named, err := pbg.V().Has(NamePredicate, name).Values()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(named) > 0 {
+			return named, nil
+		}
+	}
+
+	if pbg.store.ValueOf(value) == nil {
+		return nil, fmt.Errorf("no node %s in the graph", FormatValue(value))
+	}
+
+	return []quad.Value{ value }, nil
+}
+
+// Every predicate used in the database, sorted
+func (pbg *ProgramBehaviorGraph) Predicates() ([]string, error) {
+	ctx, cancel, timeout := pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	found := make(map[string] bool)
+
+	it := pbg.store.QuadsAllIterator()
+	defer it.Close()
+
+	err := graph.Iterate(ctx, it).Each(func(ref graph.Ref) {
+		found[nativeString(pbg.store.Quad(ref).Predicate)] = true
+	})
+
+	if err != nil {
+		return nil, queryError(ctx, timeout, err)
+	}
+
+	predicates := make([]string, 0, len(found))
+
+	for predicate := range found {
+		predicates = append(predicates, predicate)
+	}
+
+	sort.Strings(predicates)
+
+	return predicates, nil
+}
@@ -0,0 +1,333 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+	"pbg/datalog"
+)
+
+// Quads written per transaction when importing results
+const importChunkSize = 10000
+
+// Label given to imported results when no name is chosen
+const DefaultDerivedName = "datalog"
+
+// Label of results derived outside of pbg and imported back, such as the
+// output relations of a Datalog analysis
+func DerivedLabel(name string) quad.IRI {
+	return NodeID("derived", name)
+}
+
+// What ImportFacts reads and how it stores it
+type PBGFactImportOptions struct {
+	// Predicate each relation is stored as. When given, only the listed
+	// relations are imported; otherwise every relation is, with underscores
+	// in its name becoming dashes.
+	Predicates map[string] string
+
+	// Name of the label results are stored under, DefaultDerivedName if
+	// empty. Results of an earlier import under the same name are replaced.
+	Name string
+
+	// Datalog program the results were derived by, if known. Its .decl
+	// directives, and those of a schema.dl in the directory, type the
+	// columns of the relations they declare.
+	Program string
+}
+
+// Read a column of a fact file back into the value it was exported from.
+// Number columns hold addresses, undoing rewriteNumber, and pbg identities in
+// symbol columns become entities again. Columns of unknown type are numbers
+// when they read as one.
+func factValue(text string, kind PBGValueKind) quad.Value {
+	if kind != ValueSymbol {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return quad.Int(n)
+		}
+
+		if addr, err := ParseAddr(text); err == nil && kind == ValueUnknown {
+			return addr.Value()
+		}
+	}
+
+	text = datalog.UnescapeSymbol(text)
+
+	if strings.HasPrefix(text, idPrefix) {
+		return quad.IRI(text)
+	}
+
+	return quad.String(text)
+}
+
+// Column types of the relations declared by schema.dl in a directory and by
+// the program results were derived by, the latter winning
+func factTypes(dir string, program string) (map[string] []PBGValueKind, error) {
+	types := make(map[string] []PBGValueKind)
+	paths := []string{ filepath.Join(dir, "schema.dl") }
+
+	if program != "" {
+		paths = append(paths, program)
+	}
+
+	for i, path := range paths {
+		src, err := ioutil.ReadFile(path)
+
+		// Exports from before schema.dl was written don't have one
+		if i == 0 && os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		parsed, err := datalog.Parse(string(src))
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		for name, decl := range parsed.Decls {
+			kinds := make([]PBGValueKind, 0, len(decl.Columns))
+
+			for _, column := range decl.Columns {
+				if column.Type == datalog.NumberColumn {
+					kinds = append(kinds, ValueNumber)
+				} else {
+					kinds = append(kinds, ValueSymbol)
+				}
+			}
+
+			types[name] = kinds
+		}
+	}
+
+	return types, nil
+}
+
+// Relations an export wrote into a directory, which aren't results
+func exportedRelations(dir string) (map[string] bool, error) {
+	exported := make(map[string] bool)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+
+	if os.IsNotExist(err) {
+		return exported, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var manifest PBGDatalogManifest
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("manifest.json: %v", err)
+	}
+
+	for _, rel := range manifest.Relations {
+		exported[rel.Relation] = true
+	}
+
+	return exported, nil
+}
+
+// Convert the rows of a relation to quads. Pairs become an edge from the
+// first column to the second and single values an edge to true. Wider rows
+// get a node of their own, linked from the first column, with an edge per
+// remaining column named after the predicate and the column's position.
+// Columns are read as the given kinds, or guessed when there are none.
+func factQuads(path string, rel string, predicate string, label quad.IRI, kinds []PBGValueKind) ([]quad.Quad, int, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer file.Close()
+
+	quads := make([]quad.Quad, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64 * 1024), 64 * 1024 * 1024)
+	arity := -1
+	row := 0
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if line == "" {
+			continue
+		}
+
+		row += 1
+		columns := strings.Split(line, "\t")
+
+		if arity < 0 && kinds != nil && len(kinds) != len(columns) {
+			return nil, 0, fmt.Errorf("%s:%d: %s is declared with %d columns, found %d", path, row, rel, len(kinds), len(columns))
+		} else if arity < 0 {
+			arity = len(columns)
+		} else if arity != len(columns) {
+			return nil, 0, fmt.Errorf("%s:%d: expected %d columns, found %d", path, row, arity, len(columns))
+		}
+
+		value := func(i int) quad.Value {
+			if kinds == nil {
+				return factValue(columns[i], ValueUnknown)
+			}
+
+			return factValue(columns[i], kinds[i])
+		}
+
+		subject := value(0)
+
+		switch len(columns) {
+		case 1:
+			quads = append(quads, quad.Make(subject, quad.String(predicate), quad.Bool(true), label))
+		case 2:
+			quads = append(quads, quad.Make(subject, quad.String(predicate), value(1), label))
+		default:
+			tuple := ChildID(ChildID(label, "rel", rel), "row", strconv.Itoa(row))
+			quads = append(quads, quad.Make(subject, quad.String(predicate), tuple, label))
+
+			for i := 1; i < len(columns); i++ {
+				quads = append(quads, quad.Make(tuple, quad.String(fmt.Sprintf("%s-%d", predicate, i)), value(i), label))
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return quads, row, nil
+}
+
+// Load the .csv and .facts files of a directory, as written by Souffle or by
+// project query -dl, back into the graph under DerivedLabel(opts.Name), so
+// analysis results can be drawn and joined with the rest of the graph.
+// Relations exported by GenerateDatalog into the same directory are skipped.
+// Columns declared by opts.Program or schema.dl are read as their declared
+// types, others are numbers whenever they look like one. Returns the number
+// of rows imported per relation.
+func (pbg *ProgramBehaviorGraph) ImportFacts(dir string, opts PBGFactImportOptions) (map[string] int, error) {
+	name := opts.Name
+
+	if name == "" {
+		name = DefaultDerivedName
+	}
+
+	label := DerivedLabel(name)
+
+	exported, err := exportedRelations(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	types, err := factTypes(dir, opts.Program)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string] string)
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+
+		if entry.IsDir() || (ext != ".csv" && ext != ".facts") {
+			continue
+		}
+
+		rel := strings.TrimSuffix(entry.Name(), ext)
+
+		if _, ok := files[rel]; ok {
+			return nil, fmt.Errorf("both %s.csv and %s.facts found in %s", rel, rel, dir)
+		}
+
+		files[rel] = filepath.Join(dir, entry.Name())
+	}
+
+	for rel := range opts.Predicates {
+		if _, ok := files[rel]; !ok {
+			return nil, fmt.Errorf("no %s.csv or %s.facts in %s", rel, rel, dir)
+		}
+	}
+
+	rels := make([]string, 0, len(files))
+
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+
+	sort.Strings(rels)
+
+	quads := make([]quad.Quad, 0)
+	counts := make(map[string] int)
+
+	for _, rel := range rels {
+		predicate, ok := opts.Predicates[rel]
+
+		if len(opts.Predicates) == 0 {
+			if exported[rel] {
+				continue
+			}
+
+			predicate, ok = strings.ReplaceAll(rel, "_", "-"), true
+		}
+
+		if !ok {
+			continue
+		}
+
+		relQuads, rows, err := factQuads(files[rel], rel, predicate, label, types[rel])
+
+		if err != nil {
+			return nil, err
+		}
+
+		quads = append(quads, relQuads...)
+		counts[rel] = rows
+	}
+
+	stale, err := pbg.collectQuads(quad.Label, label, func(quad.Quad) bool { return true })
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stale) > 0 {
+		pbg.logger.Printf("Replacing %d quads of an earlier import into %s\n", len(stale), name)
+
+		if err := pbg.removeQuads(stale); err != nil {
+			return nil, err
+		}
+	}
+
+	pbg.writeMu.Lock()
+	defer pbg.writeMu.Unlock()
+
+	for start := 0; start < len(quads); start += importChunkSize {
+		end := start + importChunkSize
+
+		if end > len(quads) {
+			end = len(quads)
+		}
+
+		if err := pbg.store.QuadWriter.AddQuadSet(quads[start:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,112 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Collect the files an option value refers to. Strings are split on
+// whitespace so command lines count their executable, and every word is
+// expanded as a glob. Anything that isn't a regular file is ignored.
+func optionFiles(value interface{}, files map[string] bool) {
+	switch value := value.(type) {
+	case string:
+		for _, word := range strings.Fields(value) {
+			matches, err := filepath.Glob(word)
+
+			if err != nil {
+				continue
+			}
+
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+					if abs, err := filepath.Abs(match); err == nil {
+						files[abs] = true
+					}
+				}
+			}
+		}
+	case []string:
+		for _, entry := range value {
+			optionFiles(entry, files)
+		}
+	case []interface{}:
+		for _, entry := range value {
+			optionFiles(entry, files)
+		}
+	case map[string] interface{}:
+		for _, entry := range value {
+			optionFiles(entry, files)
+		}
+	}
+}
+
+// Hash everything that decides what a provider produces: its options, the
+// contents of the files they reference and, for run-scoped providers, the
+// run being recorded. Equal fingerprints mean rerunning is pointless.
+func (pbg *ProgramBehaviorGraph) providerFingerprint(provider string) (string, error) {
+	opt := pbg.options[provider]
+	hash := sha256.New()
+
+	// Keys are sorted when marshalling, so this is stable
+	encoded, err := json.Marshal(opt)
+
+	if err != nil {
+		return "", err
+	}
+
+	hash.Write(encoded)
+
+	if PBGProviderRunScoped[provider] {
+		fmt.Fprintf(hash, "\nrun %s", pbg.run)
+	}
+
+	found := make(map[string] bool)
+	optionFiles(map[string] interface{}(opt), found)
+
+	files := make([]string, 0, len(found))
+
+	for file := range found {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		fmt.Fprintf(hash, "\nfile %s\n", file)
+
+		handle, err := os.Open(file)
+
+		if err != nil {
+			return "", err
+		}
+
+		_, err = io.Copy(hash, handle)
+		handle.Close()
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Fingerprint recorded the last time a provider ran, in the current run for
+// run-scoped providers, empty if it never did
+func (pbg *ProgramBehaviorGraph) storedFingerprint(provider string) string {
+	record, err := pbg.providerRecord(provider, pbg.run)
+
+	if err != nil || record == nil {
+		return ""
+	}
+
+	return record.Fingerprint
+}
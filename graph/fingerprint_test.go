@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprintStringListFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pbg-fingerprint")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "test.c")
+
+	if err := ioutil.WriteFile(source, []byte("int main() { return 0; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// String lists are what ValidateOptions leaves OptionStringList values as
+	pbg := &ProgramBehaviorGraph{
+		options: map[string] map[string] interface{}{
+			"files": { "sourceFiles": []string{ source } },
+		},
+	}
+
+	before, err := pbg.providerFingerprint("files")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(source, []byte("int main() { return 1; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := pbg.providerFingerprint("files")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before == after {
+		t.Errorf("fingerprint %s didn't change with the contents of %s", before, source)
+	}
+}
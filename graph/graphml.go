@@ -0,0 +1,229 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// GraphML keeps node values and edge predicates and labels in data elements,
+// written with FormatValue so they read back as the same values
+const graphMLHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="value" for="node" attr.name="value" attr.type="string"/>
+  <key id="predicate" for="edge" attr.name="predicate" attr.type="string"/>
+  <key id="label" for="edge" attr.name="label" attr.type="string"/>
+  <graph id="pbg" edgedefault="directed">
+`
+
+const graphMLFooter = `  </graph>
+</graphml>
+`
+
+// Writes quads as GraphML edges, declaring each node the first time it is
+// used. Only node identities are kept in memory.
+type graphMLWriter struct {
+	w *bufio.Writer
+	nodes map[string] string
+	err error
+}
+
+func newGraphMLWriter(w io.Writer) *graphMLWriter {
+	writer := &graphMLWriter{ w: bufio.NewWriter(w), nodes: make(map[string] string) }
+	writer.write(graphMLHeader)
+
+	return writer
+}
+
+func (w *graphMLWriter) write(s string) {
+	if w.err == nil {
+		_, w.err = w.w.WriteString(s)
+	}
+}
+
+func (w *graphMLWriter) data(key string, v quad.Value) {
+	w.write(`<data key="` + key + `">`)
+
+	if w.err == nil {
+		w.err = xml.EscapeText(w.w, []byte(FormatValue(v)))
+	}
+
+	w.write(`</data>`)
+}
+
+func (w *graphMLWriter) node(v quad.Value) string {
+	text := FormatValue(v)
+
+	if id, ok := w.nodes[text]; ok {
+		return id
+	}
+
+	id := fmt.Sprintf("n%d", len(w.nodes))
+	w.nodes[text] = id
+
+	w.write(`    <node id="` + id + `">`)
+	w.data("value", v)
+	w.write("</node>\n")
+
+	return id
+}
+
+func (w *graphMLWriter) WriteQuad(q quad.Quad) error {
+	source := w.node(q.Subject)
+	target := w.node(q.Object)
+
+	w.write(`    <edge source="` + source + `" target="` + target + `">`)
+	w.data("predicate", q.Predicate)
+
+	if q.Label != nil {
+		w.data("label", q.Label)
+	}
+
+	w.write("</edge>\n")
+
+	return w.err
+}
+
+func (w *graphMLWriter) Close() error {
+	w.write(graphMLFooter)
+
+	if w.err != nil {
+		return w.err
+	}
+
+	return w.w.Flush()
+}
+
+type graphMLData struct {
+	Key string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Reads the edges of a GraphML document as quads. Data is looked up by the
+// attribute names written by graphMLWriter, so documents from other tools
+// work as long as their edges carry a predicate; nodes without a value are
+// named by their id.
+type graphMLReader struct {
+	decoder *xml.Decoder
+	keys map[string] string
+	nodes map[string] quad.Value
+}
+
+func newGraphMLReader(r io.Reader) *graphMLReader {
+	return &graphMLReader{
+		decoder: xml.NewDecoder(r),
+		keys: make(map[string] string),
+		nodes: make(map[string] quad.Value),
+	}
+}
+
+// Data of an element by attribute name, falling back to the key id
+func (r *graphMLReader) lookup(data []graphMLData, name string) (quad.Value, bool) {
+	for _, d := range data {
+		key, ok := r.keys[d.Key]
+
+		if !ok {
+			key = d.Key
+		}
+
+		if key == name {
+			return ParseValue(d.Value), true
+		}
+	}
+
+	return nil, false
+}
+
+func (r *graphMLReader) endpoint(id string) (quad.Value, error) {
+	if v, ok := r.nodes[id]; ok {
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("graphml: edge refers to undeclared node %s", id)
+}
+
+func (r *graphMLReader) ReadQuad() (quad.Quad, error) {
+	for {
+		token, err := r.decoder.Token()
+
+		if err != nil {
+			return quad.Quad{}, err
+		}
+
+		start, ok := token.(xml.StartElement)
+
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "key":
+			var key struct {
+				ID string `xml:"id,attr"`
+				Name string `xml:"attr.name,attr"`
+			}
+
+			if err := r.decoder.DecodeElement(&key, &start); err != nil {
+				return quad.Quad{}, err
+			}
+
+			r.keys[key.ID] = key.Name
+		case "node":
+			var node struct {
+				ID string `xml:"id,attr"`
+				Data []graphMLData `xml:"data"`
+			}
+
+			if err := r.decoder.DecodeElement(&node, &start); err != nil {
+				return quad.Quad{}, err
+			}
+
+			value, ok := r.lookup(node.Data, "value")
+
+			if !ok {
+				value = quad.String(node.ID)
+			}
+
+			r.nodes[node.ID] = value
+		case "edge":
+			var edge struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Data []graphMLData `xml:"data"`
+			}
+
+			if err := r.decoder.DecodeElement(&edge, &start); err != nil {
+				return quad.Quad{}, err
+			}
+
+			predicate, ok := r.lookup(edge.Data, "predicate")
+
+			if !ok {
+				return quad.Quad{}, fmt.Errorf("graphml: edge from %s to %s has no predicate", edge.Source, edge.Target)
+			}
+
+			subject, err := r.endpoint(edge.Source)
+
+			if err != nil {
+				return quad.Quad{}, err
+			}
+
+			object, err := r.endpoint(edge.Target)
+
+			if err != nil {
+				return quad.Quad{}, err
+			}
+
+			label, _ := r.lookup(edge.Data, "label")
+
+			return quad.Quad{ Subject: subject, Predicate: predicate, Object: object, Label: label }, nil
+		}
+	}
+}
+
+func (r *graphMLReader) Close() error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Structured node identities. Entities are IRIs built from a chain of
+// kind/name pairs, e.g. pbg:bin/%2Ftmp%2Fa.out/cu/.../func/main/var/i, so that
+// a variable is qualified by its function and compile unit and a section by
+// its binary. Names are escaped so paths can't break the structure.
+const idPrefix = "pbg:"
+
+// Predicate linking a structured node to its human readable name
+const NamePredicate = "has-name"
+
+// Identity of a top level entity such as a binary or a source file
+func NodeID(kind string, name string) quad.IRI {
+	return quad.IRI(idPrefix + kind + "/" + url.PathEscape(name))
+}
+
+// Identity of an entity scoped inside another one
+func ChildID(parent quad.IRI, kind string, name string) quad.IRI {
+	return quad.IRI(string(parent) + "/" + kind + "/" + url.PathEscape(name))
+}
+
+// Identity of a binary or library, keyed by its path
+func BinaryID(path string) quad.IRI {
+	return NodeID("bin", filepath.Clean(path))
+}
+
+// Identity of a source file, keyed by its path. Providers should pass absolute
+// paths so the files provider and DWARF agree on the node.
+func FileID(path string) quad.IRI {
+	return NodeID("file", filepath.Clean(path))
+}
+
+// Identity of a line within a source file
+func LineID(file quad.IRI, line int64) quad.IRI {
+	return ChildID(file, "line", strconv.FormatInt(line, 10))
+}
+
+// Identity of a recorded execution, keyed by what produced it
+func TraceID(source string) quad.IRI {
+	return NodeID("trace", source)
+}
+
+// Attach a human readable name to a structured node
+func (pbg *ProgramBehaviorGraph) AddName(id quad.IRI, name string) {
+	pbg.AddRelationValue(id, NamePredicate, name)
+}
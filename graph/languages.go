@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cayleygraph/cayley/query"
+	"github.com/cayleygraph/cayley/query/gizmo"
+	_ "github.com/cayleygraph/cayley/query/mql"
+)
+
+// Languages queries can be written in, see PBGQueryOptions. Gizmo is the
+// default; MQL, and GraphQL in builds tagged graphql, describe the shape of
+// the result instead, such as a function with its variables and their types.
+var PBGQueryLanguages = []string{ "gizmo", "mql" }
+
+// Session running queries of a language, created on first use. The Gizmo
+// session gets the query library and parameters defined first. Sessions are
+// shared by every caller of the graph, but the map holding them is guarded
+// so concurrent queries don't corrupt it.
+func (pbg *ProgramBehaviorGraph) querySession(lang string) (query.Session, error) {
+	if lang == "" {
+		lang = PBGQueryLanguages[0]
+	}
+
+	pbg.sessionMu.Lock()
+	session, ok := pbg.sessions[lang]
+
+	if !ok {
+		known := false
+
+		for _, name := range PBGQueryLanguages {
+			known = known || name == lang
+		}
+
+		if !known || query.GetLanguage(lang) == nil {
+			pbg.sessionMu.Unlock()
+
+			if lang == "graphql" {
+				return nil, fmt.Errorf("graphql queries need a build with -tags graphql")
+			}
+
+			return nil, fmt.Errorf("unknown query language %s, expected one of %s", lang, strings.Join(PBGQueryLanguages, ", "))
+		}
+
+		session = query.NewSession(pbg.store, lang)
+		pbg.sessions[lang] = session
+	}
+
+	pbg.sessionMu.Unlock()
+
+	if lang == "gizmo" {
+		if err := pbg.prepareSession(); err != nil {
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+// How results of a language are collated. Gizmo results keep their tags;
+// the declarative languages only produce JSON documents.
+func queryCollation(lang string) query.Collation {
+	if lang == "" || lang == "gizmo" {
+		return query.Raw
+	}
+
+	return query.JSON
+}
+
+// Present a result of any language as a Gizmo result, documents produced by
+// the declarative languages becoming emitted values
+func sessionResult(result interface{}) *gizmo.Result {
+	if data, ok := result.(*gizmo.Result); ok {
+		return data
+	}
+
+	return &gizmo.Result{ Val: result }
+}
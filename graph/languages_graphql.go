@@ -0,0 +1,11 @@
+// +build graphql
+
+package graph
+
+// The GraphQL frontend pulls in dependencies that haven't always built with
+// the rest of the tree, so it is only part of builds tagged graphql
+import _ "github.com/cayleygraph/cayley/query/graphql"
+
+func init() {
+	PBGQueryLanguages = append(PBGQueryLanguages, "graphql")
+}
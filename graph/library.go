@@ -0,0 +1,213 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query/gizmo"
+)
+
+// Helpers defined in every Gizmo session before the first query runs
+const QueryLibrary = `
+var params = {};
+
+// Labels of the run chosen with -run and of the output every run shares,
+// null when queries see every run
+var run = null;
+
+// Start a path like g.V, whose traversals only follow the quads of the
+// chosen run when there is one
+function V() {
+	var path = g.V.apply(g, arguments);
+	return run ? path.LabelContext(run) : path;
+}
+
+// Value of a query parameter, or fallback when it wasn't given
+function param(name, fallback) {
+	return (name in params) ? params[name] : fallback;
+}
+
+// First value a path reaches, undefined if there is none
+function first(path) {
+	return path.ToArray()[0];
+}
+
+// Readable name of a node
+function nameOf(node) {
+	return first(V(node).Out("has-name"));
+}
+
+// C spelling of a type, following pointer and qualifier types
+function resolveTypeName(typeId) {
+	var name = first(V(typeId).Out("has-type-name"));
+
+	if ( name ) {
+		return name;
+	}
+
+	var kind = first(V(typeId).OutPredicates());
+	var next = first(V(typeId).Out(kind));
+
+	if ( kind == "pointer-type" ) {
+		return resolveTypeName(next) + "*";
+	} else if ( kind == "const-type" ) {
+		return "const " + resolveTypeName(next);
+	} else if ( kind == "restrict-type" ) {
+		return "restrict " + resolveTypeName(next);
+	}
+
+	throw new Error("Unknown type id " + typeId + " (" + kind + ")");
+}
+
+// Type of a variable as written in C
+function variableType(variable) {
+	return resolveTypeName(first(V(variable).Out("has-var-type")));
+}
+
+// Location a variable or function is declared at, e.g. file.c:12
+function declLocation(node) {
+	return first(V(node).Out("decl-at").Out("has-name"));
+}
+
+// Source text of the line a variable or function is declared on
+function declLine(node) {
+	return first(V(node).Out("decl-at").Out("line-content"));
+}
+
+// Source line node an address was compiled from
+function lineAtPC(pc) {
+	return first(V().Has("text-at-pc", pc));
+}
+
+// Source text an address was compiled from
+function sourceAtPC(pc) {
+	var line = lineAtPC(pc);
+
+	if ( !line ) {
+		return undefined;
+	}
+
+	return first(V(line).Out("line-content"));
+}
+`
+
+var paramNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Make a parameter available to queries, both as a variable of that name and
+// through param(). Values that are valid JSON, such as numbers, are passed as
+// such; anything else is passed as a string.
+func (pbg *ProgramBehaviorGraph) SetQueryParam(name string, value string) error {
+	if !paramNamePattern.MatchString(name) || name == "params" || name == "run" {
+		return fmt.Errorf("invalid query parameter name %q", name)
+	}
+
+	if pbg.params == nil {
+		pbg.params = make(map[string] string)
+	}
+
+	pbg.params[name] = value
+
+	// Define it in the session before the next query
+	pbg.preloaded = false
+
+	return nil
+}
+
+// JavaScript literal of a parameter value
+func paramLiteral(value string) string {
+	if json.Valid([]byte(value)) {
+		return value
+	}
+
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// Scope the V() of queries to a run, along with the output of providers
+// outside of runs, which every run shares. An empty name lifts the scope.
+func (pbg *ProgramBehaviorGraph) SetQueryRun(name string) error {
+	pbg.queryRun = nil
+	pbg.preloaded = false
+
+	if name == "" {
+		return nil
+	}
+
+	labels, err := pbg.RunLabels(name)
+
+	if err != nil {
+		return err
+	}
+
+	records, err := pbg.ProviderRecords()
+
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.Run == "" {
+			labels = append(labels, ProviderID(record.Name))
+		}
+	}
+
+	pbg.queryRun = labels
+
+	return nil
+}
+
+// Script defining the library, parameters and run
+func (pbg *ProgramBehaviorGraph) prelude() string {
+	names := make([]string, 0, len(pbg.params))
+
+	for name := range pbg.params {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var prelude strings.Builder
+	prelude.WriteString(QueryLibrary)
+
+	for _, name := range names {
+		fmt.Fprintf(&prelude, "params[%q] = %s;\nvar %s = params[%q];\n", name, paramLiteral(pbg.params[name]), name, name)
+	}
+
+	if pbg.queryRun != nil {
+		labels := make([]string, 0, len(pbg.queryRun))
+
+		for _, label := range pbg.queryRun {
+			labels = append(labels, fmt.Sprintf("%q", quad.StringOf(label)))
+		}
+
+		fmt.Fprintf(&prelude, "run = [%s];\n", strings.Join(labels, ", "))
+	}
+
+	return prelude.String()
+}
+
+// Define the library and parameters in the session if that hasn't happened
+// yet. Definitions persist in the session across queries.
+func (pbg *ProgramBehaviorGraph) prepareSession() error {
+	if pbg.preloaded {
+		return nil
+	}
+
+	// Set first since the prelude itself runs through executeQuery
+	pbg.preloaded = true
+
+	_, err := pbg.executeQuery(pbg.prelude(), PBGQueryOptions{ Limit: -1 }, func(*gizmo.Result) error {
+		return nil
+	})
+
+	if err != nil {
+		pbg.preloaded = false
+		return fmt.Errorf("failed to load the query library: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package graph
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/cayleygraph/cayley/graph/path"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// A traversal of the graph built in Go rather than formatted as Gizmo, so
+// names containing quotes need no escaping and results come back as values.
+// Steps return a new path; the first invalid step is reported when the path
+// is evaluated.
+type PBGPath struct {
+	pbg *ProgramBehaviorGraph
+	path *path.Path
+	err error
+}
+
+// Start a traversal at the given nodes, or at every node if none are given
+func (pbg *ProgramBehaviorGraph) V(nodes ...quad.Value) *PBGPath {
+	return &PBGPath{ pbg: pbg, path: path.StartPath(pbg.store, nodes...) }
+}
+
+func (p *PBGPath) step(next *path.Path) *PBGPath {
+	return &PBGPath{ pbg: p.pbg, path: next, err: p.err }
+}
+
+func (p *PBGPath) fail(err error) *PBGPath {
+	if p.err != nil {
+		return p
+	}
+
+	return &PBGPath{ pbg: p.pbg, path: p.path, err: err }
+}
+
+func predicateVia(predicates []string) []interface{} {
+	via := make([]interface{}, 0, len(predicates))
+
+	for _, predicate := range predicates {
+		via = append(via, quad.String(predicate))
+	}
+
+	return via
+}
+
+// Follow edges with any of the predicates to their objects, or every edge if
+// none are given
+func (p *PBGPath) Out(predicates ...string) *PBGPath {
+	return p.step(p.path.Out(predicateVia(predicates)...))
+}
+
+// Follow edges with any of the predicates back to their subjects
+func (p *PBGPath) In(predicates ...string) *PBGPath {
+	return p.step(p.path.In(predicateVia(predicates)...))
+}
+
+// Keep nodes with an edge to any of the values. Values are converted like
+// the objects of AddRelationValue, so strings, addresses and IRIs all work.
+func (p *PBGPath) Has(predicate string, values ...interface{}) *PBGPath {
+	nodes := make([]quad.Value, 0, len(values))
+
+	for _, value := range values {
+		node, err := toValue(value)
+
+		if err != nil {
+			return p.fail(err)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return p.step(p.path.Has(quad.String(predicate), nodes...))
+}
+
+// Keep nodes whose value matches a regular expression
+func (p *PBGPath) Regex(pattern string) *PBGPath {
+	re, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return p.fail(err)
+	}
+
+	return p.step(p.path.Regex(re))
+}
+
+// Name the current nodes so they can be read back with Tagged
+func (p *PBGPath) Tag(tags ...string) *PBGPath {
+	return p.step(p.path.Tag(tags...))
+}
+
+// Return to the nodes saved under a tag
+func (p *PBGPath) Back(tag string) *PBGPath {
+	return p.step(p.path.Back(tag))
+}
+
+func (p *PBGPath) Unique() *PBGPath {
+	return p.step(p.path.Unique())
+}
+
+// Every value the path reaches
+func (p *PBGPath) Values() ([]quad.Value, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	ctx, cancel, timeout := p.pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	values, err := p.path.Iterate(ctx).AllValues(p.pbg.store)
+
+	if err != nil {
+		return nil, queryError(ctx, timeout, err)
+	}
+
+	return values, nil
+}
+
+// Every value the path reaches as a plain string, without quotes or IRI
+// brackets
+func (p *PBGPath) Strings() ([]string, error) {
+	values, err := p.Values()
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]string, 0, len(values))
+
+	for _, value := range values {
+		results = append(results, nativeString(value))
+	}
+
+	return results, nil
+}
+
+// Every entity the path reaches, failing on literals
+func (p *PBGPath) IRIs() ([]quad.IRI, error) {
+	values, err := p.Values()
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]quad.IRI, 0, len(values))
+
+	for _, value := range values {
+		iri, ok := value.(quad.IRI)
+
+		if !ok {
+			return nil, fmt.Errorf("expected an entity, got %s", quad.StringOf(value))
+		}
+
+		results = append(results, iri)
+	}
+
+	return results, nil
+}
+
+// Every address the path reaches, failing on anything else
+func (p *PBGPath) Addrs() ([]Addr, error) {
+	values, err := p.Values()
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Addr, 0, len(values))
+
+	for _, value := range values {
+		addr, ok := AddrOf(value)
+
+		if !ok {
+			return nil, fmt.Errorf("expected an address, got %s", quad.StringOf(value))
+		}
+
+		results = append(results, addr)
+	}
+
+	return results, nil
+}
+
+// The tagged values of every result
+func (p *PBGPath) Tagged() ([]map[string] quad.Value, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	ctx, cancel, timeout := p.pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	results := make([]map[string] quad.Value, 0)
+
+	err := p.path.Iterate(ctx).TagValues(p.pbg.store, func(tags map[string] quad.Value) {
+		results = append(results, tags)
+	})
+
+	if err != nil {
+		return nil, queryError(ctx, timeout, err)
+	}
+
+	return results, nil
+}
@@ -2,8 +2,12 @@ package graph
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cayleygraph/cayley"
@@ -19,21 +23,55 @@ const PBG_QUERY_LIMIT = 2500;
 
 type ProgramBehaviorGraph struct {
 	store *cayley.Handle	
-	session query.Session
+	// Query sessions by language, see querySession
+	sessions map[string] query.Session
+	sessionMu sync.Mutex
 	options map [string] map[string] interface{}
 
+	// Shared by every provider view, serializes writes to the store
+	writeMu *sync.Mutex
+	logger *log.Logger
+
+	// Maximum number of providers executing at once, and what to do when one
+	// of them fails
+	workers int
+	errorPolicy PBGErrorPolicy
+
+	// Results returned by queries that don't set a limit, see SetQueryLimit
+	queryLimit int
+
+	// What queries run under unless they ask otherwise, see SetContext and
+	// SetQueryTimeout
+	ctx context.Context
+	queryTimeout time.Duration
+
+	// Parameters handed to queries, and whether they and the query library
+	// are defined in the session yet
+	params map[string] string
+	preloaded bool
+
+	// Labels V() is scoped to in queries, see SetQueryRun
+	queryRun []quad.IRI
+
+	// Run that run-scoped providers write into, and the label of current writes,
+	// which names the provider (and run) that produced them
+	run string
+	label quad.Value
+
 	// Automatically handle bulking
 	autoBulk int
-	bulkBuf [][]string
+	bulkBuf []quad.Quad
 
 	reservoirIndex int
 	reservoirSize int
-	reservoir [][]string 
+	reservoir []quad.Quad
+
+	// First error hit while writing, see Err
+	err error
 }
 
 // Constructs a new ProgramBehaviorGraph object from a dbpath and handler.
-// For now it is assumed that a Gizmo query tool is desired, however, this
-// might change in the future.
+// Queries are run in Gizmo unless they ask for another language.
 func NewPBG(db string, path string, init bool) (*ProgramBehaviorGraph, error) {
 	var store *cayley.Handle
 	var err error
@@ -62,13 +100,105 @@ func NewPBG(db string, path string, init bool) (*ProgramBehaviorGraph, error) {
 
 	obj.store = store;
 	obj.options = make(map [string] map[string] interface{})
-
-	// Temporarily disabled while graphql doesn't build
-	obj.session = query.NewSession(store, "gizmo")
+	obj.writeMu = &sync.Mutex{}
+	obj.logger = log.New(log.Writer(), "[PBG] ", log.Flags())
+	obj.sessions = make(map[string] query.Session)
 
 	return obj, nil;
 }
 
+// Create the view of the graph handed to a provider. It shares the store with
+// every other view but has its own buffers, label, query session and log
+// prefix, so several providers can write at the same time. Writes are labeled
+// with the provider so its output can be found again and replaced.
+func (pbg *ProgramBehaviorGraph) providerView(provider string) *ProgramBehaviorGraph {
+	view := new(ProgramBehaviorGraph)
+
+	view.store = pbg.store
+	view.options = pbg.options
+	view.writeMu = pbg.writeMu
+	view.run = pbg.run
+	view.queryLimit = pbg.queryLimit
+	view.ctx = pbg.ctx
+	view.queryTimeout = pbg.queryTimeout
+	view.params = pbg.params
+	view.label = ProviderID(provider)
+	view.logger = log.New(log.Writer(), "[" + strings.ToUpper(provider) + "] ", log.Flags())
+	view.sessions = make(map[string] query.Session)
+
+	return view
+}
+
+// Create a view of the graph with query sessions of its own. Variables and
+// functions a query defines stay in its view, so clients that shouldn't see
+// each other's globals can each query through a fresh one.
+func (pbg *ProgramBehaviorGraph) QueryView() *ProgramBehaviorGraph {
+	view := new(ProgramBehaviorGraph)
+
+	view.store = pbg.store
+	view.options = pbg.options
+	view.writeMu = pbg.writeMu
+	view.run = pbg.run
+	view.label = pbg.label
+	view.queryLimit = pbg.queryLimit
+	view.ctx = pbg.ctx
+	view.queryTimeout = pbg.queryTimeout
+	view.params = pbg.params
+	view.queryRun = pbg.queryRun
+	view.logger = pbg.logger
+	view.sessions = make(map[string] query.Session)
+
+	return view
+}
+
+// Logger prefixed with the name of the provider using this graph
+func (pbg *ProgramBehaviorGraph) Logger() *log.Logger {
+	return pbg.logger
+}
+
+// First error hit while adding relations, if any. Adding relations doesn't
+// return errors so providers can write freely and check once at the end;
+// providers run by ExecuteProviders are checked for them.
+func (pbg *ProgramBehaviorGraph) Err() error {
+	return pbg.err
+}
+
+// Remember a write error, keeping the first one as it is the most telling
+func (pbg *ProgramBehaviorGraph) fail(err error) {
+	if pbg.err == nil {
+		pbg.err = err
+	}
+}
+
+// Write quads to the store, safe to call from concurrent providers
+func (pbg *ProgramBehaviorGraph) writeQuads(quads []quad.Quad) {
+	pbg.writeMu.Lock()
+	err := pbg.store.QuadWriter.AddQuadSet(quads)
+	pbg.writeMu.Unlock()
+
+	if err != nil {
+		pbg.fail(err)
+	}
+}
+
+// Build a quad under the current label from values accepted by
+// AddRelationValue
+func (pbg *ProgramBehaviorGraph) makeQuad(from interface{}, rel string, to interface{}) (quad.Quad, error) {
+	subject, err := toValue(from)
+
+	if err != nil {
+		return quad.Quad{}, err
+	}
+
+	object, err := toValue(to)
+
+	if err != nil {
+		return quad.Quad{}, err
+	}
+
+	return quad.Make(subject, quad.String(rel), object, pbg.label), nil
+}
+
 // Sets the options for a given provider
 func (pbg *ProgramBehaviorGraph) SetOptions(provider string, options map[string] interface{}) {
 	pbg.options[provider] = options
@@ -77,15 +207,33 @@ func (pbg *ProgramBehaviorGraph) SetOptions(provider string, options map[string]
 // Adds a triplet relation (subject, verb, object) to the graph. This API may change
 // in the future to support contexts.
 func (pbg *ProgramBehaviorGraph) AddRelation(from string, rel string, to string) {
+	pbg.AddRelationValue(from, rel, to)
+}
+
+// Adds a relation whose object is a program address.
+func (pbg *ProgramBehaviorGraph) AddRelationAddr(from string, rel string, to Addr) {
+	pbg.AddRelationValue(from, rel, to)
+}
+
+// Adds a relation between arbitrary values. Strings, addresses and quad values
+// are all accepted for the subject and object.
+func (pbg *ProgramBehaviorGraph) AddRelationValue(from interface{}, rel string, to interface{}) {
+	q, err := pbg.makeQuad(from, rel, to)
+
+	if err != nil {
+		pbg.fail(err)
+		return
+	}
+
 	if pbg.autoBulk > 0 {
-		pbg.bulkBuf = append(pbg.bulkBuf, []string{ from, rel, to });
+		pbg.bulkBuf = append(pbg.bulkBuf, q);
 
 		if len(pbg.bulkBuf) > pbg.autoBulk {
-			pbg.AddRelationBulk(pbg.bulkBuf)
+			pbg.addQuads(pbg.bulkBuf)
 			pbg.bulkBuf = pbg.bulkBuf[:0]
 		}
 	} else {
-		pbg.store.AddQuad(quad.Make(from, rel, to, nil));
+		pbg.writeQuads([]quad.Quad{ q });
 	}
 }
 
@@ -94,11 +242,11 @@ func (pbg *ProgramBehaviorGraph) SetAutoBulk(count int) {
 	pbg.autoBulk = count
 
 	if count > 0 {
-		pbg.bulkBuf = make([][]string, 0)
+		pbg.bulkBuf = make([]quad.Quad, 0)
 	} else {
 		// Left over in bulk buffer
 		if len(pbg.bulkBuf) > 0 {
-			pbg.AddRelationBulk(pbg.bulkBuf)
+			pbg.addQuads(pbg.bulkBuf)
 			pbg.bulkBuf = pbg.bulkBuf[:0]
 		}
 	}
@@ -112,19 +260,19 @@ func (pbg *ProgramBehaviorGraph) SetReservoir(count int) {
 	pbg.reservoirSize = count
 
 	if count > 0 {
-		pbg.reservoir = make([][]string, 0)
+		pbg.reservoir = make([]quad.Quad, 0)
 	} else {
 		if len(pbg.reservoir) > 0 {
 			// Commit reservoir
-			pbg.AddRelationBulk(pbg.reservoir)
+			pbg.writeQuads(pbg.reservoir)
 			pbg.reservoir = pbg.reservoir[:0]
 		}
 	}
 }
 
 // Add to reservoir
-func (pbg *ProgramBehaviorGraph) AddRelationReservoir(data [][]string) {
-	for _, piece := range data {
+func (pbg *ProgramBehaviorGraph) addReservoir(quads []quad.Quad) {
+	for _, piece := range quads {
 		if pbg.reservoirIndex < pbg.reservoirSize {
 			pbg.reservoir = append(pbg.reservoir, piece)
 		} else {
@@ -135,34 +283,68 @@ func (pbg *ProgramBehaviorGraph) AddRelationReservoir(data [][]string) {
 	}
 }
 
-// Executes a bulk form of AddRelation. Assumes that the array contains a list of 3-lists
-func (pbg *ProgramBehaviorGraph) AddRelationBulk(data [][]string) {
+// Write a set of quads, going through the reservoir if one is enabled
+func (pbg *ProgramBehaviorGraph) addQuads(quads []quad.Quad) {
 	if pbg.reservoirSize > 0 {
-		pbg.AddRelationReservoir(data)
+		pbg.addReservoir(quads)
 		return
 	}
 
+	pbg.writeQuads(quads)
+}
+
+// Executes a bulk form of AddRelation. Assumes that the array contains a list of 3-lists
+// of values accepted by AddRelationValue.
+func (pbg *ProgramBehaviorGraph) AddRelationBulk(data [][]interface{}) {
 	quads := make([]quad.Quad, len(data));
-	i := 0
-	for _, piece := range data {
+
+	for i, piece := range data {
 		if len(piece) != 3 {
-			panic("Invalid bulk piece")
+			pbg.fail(fmt.Errorf("invalid bulk piece %v", piece))
+			return
+		}
+
+		rel, ok := piece[1].(string)
+
+		if !ok {
+			pbg.fail(fmt.Errorf("invalid bulk predicate %v", piece[1]))
+			return
 		}
-		quads[i] = quad.Make(piece[0], piece[1], piece[2], "")
-		// quads = append(quads, quad.Make(piece[0], piece[1], piece[2], ""));
+
+		q, err := pbg.makeQuad(piece[0], rel, piece[2])
+
+		if err != nil {
+			pbg.fail(err)
+			return
+		}
+
+		quads[i] = q
 	}
 
-	pbg.store.QuadWriter.AddQuadSet(quads)
-	quads = nil
+	pbg.addQuads(quads)
 }
 
-// Executes a function and chunks up its output to add to addRealtionBulk
-func (pbg *ProgramBehaviorGraph) AddRelationFunc(produce func (chan []string)) {
-	relationChannel := make(chan []string, 0)
+// Executes a function and chunks up its output to add to addRealtionBulk. The
+// function shouldn't close the channel, and its error is kept like any other
+// write error.
+func (pbg *ProgramBehaviorGraph) AddRelationFunc(produce func (chan []interface{}) error) {
+	relationChannel := make(chan []interface{}, 0)
+	var produceErr error
 
-	go produce(relationChannel)
+	// The channel is closed for the producer, even when it fails
+	go func() {
+		defer close(relationChannel)
 
-	tmpBuffer := make([][]string, 0);
+		defer func() {
+			if r := recover(); r != nil {
+				produceErr = fmt.Errorf("relation producer panicked: %v", r)
+			}
+		}()
+
+		produceErr = produce(relationChannel)
+	}()
+
+	tmpBuffer := make([][]interface{}, 0);
 	upperBound := 30000
 
 	for output := range relationChannel {
@@ -170,7 +352,7 @@ func (pbg *ProgramBehaviorGraph) AddRelationFunc(produce func (chan []string)) {
 
 		if len(tmpBuffer) >= upperBound {
 			pbg.AddRelationBulk(tmpBuffer);
-			tmpBuffer = make([][]string, 0);
+			tmpBuffer = make([][]interface{}, 0);
 			upperBound = upperBound * 2
 		}
 	}
@@ -178,126 +360,324 @@ func (pbg *ProgramBehaviorGraph) AddRelationFunc(produce func (chan []string)) {
 	if len(tmpBuffer) > 0 {
 		pbg.AddRelationBulk(tmpBuffer);
 	}
+
+	if produceErr != nil {
+		pbg.fail(produceErr)
+	}
 }
 
 
-// Execute a query based on the loaded session and return results/errors.
-func (pbg *ProgramBehaviorGraph) Query(qu string) ([]string, error) {
-	ctx := context.TODO()
+// Controls how many results a query returns
+type PBGQueryOptions struct {
+	// Maximum number of results. Zero uses the graph's limit (see
+	// SetQueryLimit) and a negative value returns everything.
+	Limit int
+
+	// Number of results to skip, for reading a query page by page
+	Offset int
+
+	// Stops the query when cancelled. Nil uses the graph's context, see
+	// SetContext.
+	Context context.Context
+
+	// Maximum time the query may take. Zero uses the graph's timeout (see
+	// SetQueryTimeout) and a negative value waits indefinitely.
+	Timeout time.Duration
+
+	// One of PBGQueryLanguages, Gizmo when empty
+	Language string
+}
+
+// Where a query stopped. When results were cut off by the limit, Next is the
+// offset that continues with the following page.
+type PBGQueryPage struct {
+	Truncated bool
+	Next int
+}
+
+// Limit applied to queries that don't ask for one. Zero restores the default
+// of PBG_QUERY_LIMIT and a negative value disables the limit.
+func (pbg *ProgramBehaviorGraph) SetQueryLimit(limit int) {
+	pbg.queryLimit = limit
+}
+
+// Run a query and hand every result within the requested page to a callback
+func (pbg *ProgramBehaviorGraph) executeQuery(qu string, opts PBGQueryOptions, each func(*gizmo.Result) error) (PBGQueryPage, error) {
+	ctx, cancel, timeout := pbg.queryContext(opts)
+	defer cancel()
+
+	page := PBGQueryPage{}
+
+	session, err := pbg.querySession(opts.Language)
+
+	if err != nil {
+		return page, err
+	}
+
+	limit := opts.Limit
+
+	if limit == 0 {
+		limit = pbg.queryLimit
+	}
 
-	it, err := pbg.session.Execute(ctx,  qu, query.Options{
-			Collation: query.Raw,
-			Limit: PBG_QUERY_LIMIT,
+	if limit == 0 {
+		limit = PBG_QUERY_LIMIT
+	}
+
+	offset := opts.Offset
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	// One result past the page tells whether anything was left out
+	sessionLimit := 0
+
+	if limit > 0 {
+		sessionLimit = offset + limit + 1
+	}
+
+	it, err := session.Execute(ctx,  qu, query.Options{
+			Collation: queryCollation(opts.Language),
+			Limit: sessionLimit,
 	})
 
 	if err != nil {
-		panic(err)
+		return page, queryError(ctx, timeout, err);
 	}
 
-	var results []string
+	defer it.Close()
+
+	index := 0
 
 	for it.Next(ctx) {
-		data := it.Result().(*gizmo.Result)
+		if index < offset {
+			index += 1
+			continue
+		}
+
+		if limit > 0 && index >= offset + limit {
+			page.Truncated = true
+			page.Next = offset + limit
+			break
+		}
+
+		if err := each(sessionResult(it.Result())); err != nil {
+			return page, err
+		}
+
+		index += 1
+	}
+
+	// An interrupted iterator may simply stop without an error
+	if err := queryError(ctx, timeout, it.Err()); err != nil {
+		return page, err;
+	}
+
+	return page, nil;
+}
 
+// Let the user know a query returned less than it matched
+func (pbg *ProgramBehaviorGraph) warnTruncated(page PBGQueryPage) {
+	if page.Truncated {
+		pbg.logger.Printf("Query results were truncated after %d results, raise the query limit to see the rest\n", page.Next)
+	}
+}
+
+// Execute a query based on the loaded session and return results/errors.
+// Results past the graph's query limit are dropped with a warning.
+func (pbg *ProgramBehaviorGraph) Query(qu string) ([]string, error) {
+	results, page, err := pbg.QueryPage(qu, PBGQueryOptions{})
+	pbg.warnTruncated(page)
+
+	return results, err
+}
+
+// Execute a query and return one page of its results
+func (pbg *ProgramBehaviorGraph) QueryPage(qu string, opts PBGQueryOptions) ([]string, PBGQueryPage, error) {
+	var results []string
+
+	page, err := pbg.executeQuery(qu, opts, func(data *gizmo.Result) error {
 		if data.Val == nil {
 			if val := data.Tags[gizmo.TopResultTag]; val != nil {
 				found_str := quad.StringOf(pbg.store.NameOf(val));
 				results = append(results, found_str);
 			} else {
-				panic(fmt.Sprintf("Unknown data %v", data))
+				return fmt.Errorf("unknown query result %v", data)
 			}
 		} else {
 			switch val := data.Val.(type) {
 			case string:
 				results = append(results, val);
+			case map[string] interface{}, []interface{}:
+				// Documents from GraphQL and MQL read best as JSON
+				encoded, err := json.Marshal(val)
+
+				if err != nil {
+					return err
+				}
+
+				results = append(results, string(encoded));
 			default:
 				results = append(results, fmt.Sprint(val));
 			}
 		}
-	}
 
-	if err := it.Err(); err != nil {
-		panic(err)
-		return nil, err;
+		return nil
+	})
+
+	if err != nil {
+		return nil, page, err;
 	}
 
-	return results, nil;
+	return results, page, nil;
 }
 
-type PBGTriplet struct {
-	subject string
-	predicate string
-	object string
+// Execute a query and return the raw quad values instead of their string
+// form. Emitted values are converted to the closest matching quad value.
+// Unlike Query this isn't limited, as it mostly serves internal lookups.
+func (pbg *ProgramBehaviorGraph) QueryValues(qu string) ([]quad.Value, error) {
+	results, _, err := pbg.QueryValuesPage(qu, PBGQueryOptions{ Limit: -1 })
+
+	return results, err
 }
 
-func (pbg *ProgramBehaviorGraph) QueryTriplet(qu string) ([]PBGTriplet, error) {
-	ctx := context.TODO()
+// Execute a query and return one page of its results as quad values
+func (pbg *ProgramBehaviorGraph) QueryValuesPage(qu string, opts PBGQueryOptions) ([]quad.Value, PBGQueryPage, error) {
+	var results []quad.Value
+
+	page, err := pbg.executeQuery(qu, opts, func(data *gizmo.Result) error {
+		if data.Val == nil {
+			if val := data.Tags[gizmo.TopResultTag]; val != nil {
+				results = append(results, pbg.store.NameOf(val));
+			} else {
+				return fmt.Errorf("unknown query result %v", data)
+			}
+		} else if val, ok := quad.AsValue(data.Val); ok {
+			results = append(results, val);
+		} else {
+			results = append(results, quad.String(fmt.Sprint(data.Val)));
+		}
 
-	it, err := pbg.session.Execute(ctx,  qu, query.Options{
-			Collation: query.Raw,
-			Limit: PBG_QUERY_LIMIT,
+		return nil
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, page, err;
 	}
 
-	var results []PBGTriplet
+	return results, page, nil;
+}
 
-	for it.Next(ctx) {
-		data := it.Result().(*gizmo.Result)
+type PBGTriplet struct {
+	subject quad.Value
+	predicate quad.Value
+	object quad.Value
+}
 
-		if data.Val == nil {
-			subject := quad.StringOf(pbg.store.NameOf(data.Tags["subject"]))
-			predicate := quad.StringOf(pbg.store.NameOf(data.Tags["predicate"]))
-			object := quad.StringOf(pbg.store.NameOf(data.Tags["object"]))
+func (t PBGTriplet) Subject() quad.Value { return t.subject }
+func (t PBGTriplet) Predicate() quad.Value { return t.predicate }
+func (t PBGTriplet) Object() quad.Value { return t.object }
 
-			results = append(results, PBGTriplet { subject, predicate, object })
-		} else {
-			panic("Failed to get triplet")
+// Read a triplet out of a result tagged with subject, predicate and object
+func (pbg *ProgramBehaviorGraph) resultTriplet(data *gizmo.Result) (PBGTriplet, error) {
+	if data.Val != nil {
+		return PBGTriplet{}, fmt.Errorf("expected a subject/predicate/object result, got %v", data.Val)
+	}
+
+	for _, tag := range []string{ "subject", "predicate", "object" } {
+		if _, ok := data.Tags[tag]; !ok {
+			return PBGTriplet{}, fmt.Errorf("query result is missing the %s tag", tag)
 		}
 	}
 
-	if err := it.Err(); err != nil {
-		panic(err)
-		return nil, err;
+	subject := pbg.store.NameOf(data.Tags["subject"])
+	predicate := pbg.store.NameOf(data.Tags["predicate"])
+	object := pbg.store.NameOf(data.Tags["object"])
+
+	return PBGTriplet { subject, predicate, object }, nil
+}
+
+// Execute a query tagging subject, predicate and object. Results past the
+// graph's query limit are dropped with a warning.
+func (pbg *ProgramBehaviorGraph) QueryTriplet(qu string) ([]PBGTriplet, error) {
+	results, page, err := pbg.QueryTripletPage(qu, PBGQueryOptions{})
+	pbg.warnTruncated(page)
+
+	return results, err
+}
+
+// Execute a query tagging subject, predicate and object and return one page
+// of its triplets
+func (pbg *ProgramBehaviorGraph) QueryTripletPage(qu string, opts PBGQueryOptions) ([]PBGTriplet, PBGQueryPage, error) {
+	var results []PBGTriplet
+
+	page, err := pbg.executeQuery(qu, opts, func(data *gizmo.Result) error {
+		triplet, err := pbg.resultTriplet(data)
+
+		if err != nil {
+			return err
+		}
+
+		results = append(results, triplet)
+		return nil
+	})
+
+	if err != nil {
+		return nil, page, err;
 	}
 
-	return results, nil;
+	return results, page, nil;
 }
 
-func (pbg *ProgramBehaviorGraph) QueryTripletAsync(qu string) chan PBGTriplet {
-	ctx := context.TODO()
+// Stream the triplets of a query. The triplet channel is closed once the query
+// is done, after which the error channel yields its outcome. Consumers that
+// stop reading early must cancel opts.Context so the query is torn down.
+func (pbg *ProgramBehaviorGraph) QueryTripletAsync(qu string, opts PBGQueryOptions) (chan PBGTriplet, chan error) {
+	ctx, cancel, timeout := pbg.queryContext(opts)
 	ch := make(chan PBGTriplet, 0)
+	errc := make(chan error, 1)
 
 	go func(ch chan PBGTriplet) {
-		it, err := pbg.session.Execute(ctx,  qu, query.Options{ Collation: query.Raw })
+		defer close(errc)
+		defer close(ch)
+		defer cancel()
+
+		session, err := pbg.querySession(opts.Language)
 
 		if err != nil {
-			panic(err)
+			errc <- err
+			return
 		}
 
-		for it.Next(ctx) {
-			data := it.Result().(*gizmo.Result)
+		it, err := session.Execute(ctx,  qu, query.Options{ Collation: queryCollation(opts.Language) })
 
-			if data.Val == nil {
-				subject := quad.StringOf(pbg.store.NameOf(data.Tags["subject"]))
-				predicate := quad.StringOf(pbg.store.NameOf(data.Tags["predicate"]))
-				object := quad.StringOf(pbg.store.NameOf(data.Tags["object"]))
+		if err != nil {
+			errc <- queryError(ctx, timeout, err)
+			return
+		}
 
-				ch <- PBGTriplet { subject, predicate, object }
-			} else {
-				panic("Failed to get triplet")
+		defer it.Close()
+
+		for it.Next(ctx) {
+			triplet, err := pbg.resultTriplet(sessionResult(it.Result()))
+
+			if err != nil {
+				errc <- err
+				return
 			}
-		}
 
-		if err := it.Err(); err != nil {
-			panic(err)
+			select {
+			case ch <- triplet:
+			case <-ctx.Done():
+				errc <- queryError(ctx, timeout, nil)
+				return
+			}
 		}
 
-		close(ch)
+		errc <- queryError(ctx, timeout, it.Err())
 	}(ch)
 
 
-	return ch;
+	return ch, errc;
 }
@@ -3,18 +3,61 @@ package graph
 import (
 	"fmt"
 	"log"
-	"container/heap"
+	"runtime"
 	"sync"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/cayleygraph/cayley/quad"
 )
 
-type PBGProvider func (pbg *ProgramBehaviorGraph, opt map[string] interface{});
+type PBGProvider func (pbg *ProgramBehaviorGraph, opt map[string] interface{}) error;
+
+// How ExecuteProviders reacts to a failing provider
+type PBGErrorPolicy int
+
+const (
+	// Start nothing new after the first failure, letting running providers
+	// finish
+	AbortOnError PBGErrorPolicy = iota
+
+	// Keep executing everything that doesn't depend on a failed provider
+	ContinueOnError
+)
+
+// A provider that failed, either by returning an error, failing to write its
+// output or panicking
+type PBGProviderError struct {
+	Provider string
+	Err error
+}
+
+func (e *PBGProviderError) Error() string {
+	return fmt.Sprintf("provider %s: %v", e.Provider, e.Err)
+}
 
-type PBGProviderDistCacheEntry struct {
-	from string;
-	to string
+// Every failure of an execution, along with the providers that didn't run
+// because of them
+type PBGExecutionError struct {
+	Failed []*PBGProviderError
+	Skipped []string
+}
+
+func (e *PBGExecutionError) Error() string {
+	failures := make([]string, 0, len(e.Failed))
+
+	for _, failure := range e.Failed {
+		failures = append(failures, failure.Error())
+	}
+
+	msg := strings.Join(failures, "; ")
+
+	if len(e.Skipped) > 0 {
+		msg += fmt.Sprintf(" (skipped %s)", strings.Join(e.Skipped, ", "))
+	}
+
+	return msg
 }
 
 // Mutex locking all the following
@@ -29,32 +72,6 @@ var PBGProviderBackDepList map[string] []string;
 // List of providers that depend on a given string.
 var PBGProviderForwardDepList map[string] []string;
 
-// A cache for provider -> upstream dependency distances
-var PBGProviderDistCache map[PBGProviderDistCacheEntry] int;
-
-// Get the root providers with no dependencies
-func getProviderRoots() []string {
-	roots := make([]string, 0);
-
-	PBGProviderListMutex.Lock();
-
-	for provider, deps := range PBGProviderBackDepList {
-		if len(deps) == 0 {
-			roots = append(roots, provider);
-		}
-	}
-
-	PBGProviderListMutex.Unlock();
-
-	return roots;
-}
-
-// A dependency / root-distance pair
-type PBGDepDist struct {
-	dep string; // Dependency in question
-	dist int;	// It's longest distance to the given dependency
-}
-
 // Initialize the global objects for provider lists
 func initProviderObjects() {
 	if PBGProviderList != nil {
@@ -64,189 +81,629 @@ func initProviderObjects() {
 	PBGProviderList = make(map[string] PBGProvider, 0);
 	PBGProviderBackDepList = make(map [string] []string, 0);
 	PBGProviderForwardDepList = make(map [string] []string, 0);
-	PBGProviderDistCache = make(map [PBGProviderDistCacheEntry] int, 0);
+	PBGProviderRunScoped = make(map [string] bool, 0);
+	PBGProviderSpecs = make(map [string] *PBGProviderSpec, 0);
 	PBGProviderListMutex = &sync.Mutex{}
 }
 
-// Locked version of getDepDistance. Does the actual calculation.
-func lockedGetDepDistance(from string, to string, curr int) (int, bool) {
-	pair := PBGProviderDistCacheEntry { from, to };
+// Find a dependency cycle among the given providers, returned as the path
+// that leads back to its start. Used to explain why no order exists.
+func lockedFindCycle(providers []string) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	state := make(map[string] int)
+	stack := make([]string, 0)
+
+	var visit func(provider string) []string
+
+	visit = func(provider string) []string {
+		state[provider] = visiting
+		stack = append(stack, provider)
+
+		for _, dep := range PBGProviderBackDepList[provider] {
+			if state[dep] == visiting {
+				// Cut the stack down to the cycle itself
+				for i, entry := range stack {
+					if entry == dep {
+						return append(append([]string{}, stack[i:]...), dep)
+					}
+				}
+			} else if state[dep] == unvisited {
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack) - 1]
+		state[provider] = done
 
-	if _, ok := PBGProviderDistCache[pair]; ok {
-		return curr + PBGProviderDistCache[pair], true;
+		return nil
 	}
 
-	chans := make([](chan int), 0);
+	for _, provider := range providers {
+		if state[provider] == unvisited {
+			if cycle := visit(provider); cycle != nil {
+				return cycle
+			}
+		}
+	}
 
-	for _, dep := range PBGProviderBackDepList[from] {
-		if dep == to {
-			return curr, false;
+	return nil
+}
+
+// Check the registered providers and compute an order in which every
+// provider comes after all of its dependencies. Ties are broken by name so
+// the plan is stable between runs.
+func lockedPlanProviders() ([]string, error) {
+	names := make([]string, 0, len(PBGProviderList))
+
+	for name := range PBGProviderList {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	// Every dependency has to exist before we can order anything
+	for _, name := range names {
+		for _, dep := range PBGProviderBackDepList[name] {
+			if _, ok := PBGProviderList[dep]; !ok {
+				return nil, fmt.Errorf("provider %s depends on unknown provider %s", name, dep)
+			}
 		}
+	}
 
-		// Recursively and parallel-ly check its dependencies
-		ch := make(chan int, 0);
+	// Kahn's algorithm over the dependency edges
+	remaining := make(map[string] int)
+	ready := make([]string, 0)
 
-		go func () {
-			res, _ := lockedGetDepDistance(dep, to, curr + 1);
-			log.Printf("Getting dep from %s to %s\n", dep, to);
-			ch <- res
-			close(ch);
-		}();
+	for _, name := range names {
+		remaining[name] = len(PBGProviderBackDepList[name])
+
+		if remaining[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	plan := make([]string, 0, len(names))
+
+	for len(ready) > 0 {
+		provider := ready[0]
+		ready = ready[1:]
+		plan = append(plan, provider)
+
+		for _, dependent := range PBGProviderForwardDepList[provider] {
+			remaining[dependent] -= 1
+
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
 
-		chans = append(chans, ch);
+		sort.Strings(ready)
 	}
 
-	// Wait for the results and take the max.
-	max := -1;
+	if len(plan) != len(names) {
+		blocked := make([]string, 0)
 
-	for _, ch := range chans {
-	 	for msg := range ch {
-			if msg > max {
-				max = msg;
+		for _, name := range names {
+			if remaining[name] > 0 {
+				blocked = append(blocked, name)
 			}
 		}
+
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(lockedFindCycle(blocked), " -> "))
 	}
 
-	return max, false;
+	return plan, nil
 }
 
-// Calculate the amount of links between two providers via dependencies.
-// Used internally for a topographic sort of the providers to create a safe
-// execution order.
-func getDepDistance(from string, to string) int {
-	PBGProviderListMutex.Lock();
+// Selects which providers a plan includes
+type PBGProviderFilter struct {
+	// Only run these providers and everything they depend on
+	Whitelist []string
 
-	res, cached := lockedGetDepDistance(from, to, 0);
+	// Never run these providers, nor anything that needs their output
+	Blacklist []string
+
+	// Rely on the output of dependencies that already ran against the
+	// database instead of running them again
+	Reuse bool
+}
 
-	// Store newly computed results
-	if !cached {
-		PBGProviderDistCache[PBGProviderDistCacheEntry {from, to}] = res;
+// Check that every named provider exists
+func lockedCheckNames(names []string, list string) error {
+	for _, name := range names {
+		if _, ok := PBGProviderList[name]; !ok {
+			return fmt.Errorf("unknown provider %s in %s", name, list)
+		}
 	}
 
-	PBGProviderListMutex.Unlock();
+	return nil
+}
+
+// Drop empty entries left over from splitting flags
+func cleanNames(names []string) []string {
+	cleaned := make([]string, 0, len(names))
 
-	return res;
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			cleaned = append(cleaned, name)
+		}
+	}
+
+	return cleaned
 }
 
-func (pbg *ProgramBehaviorGraph) SetProviderOptions(provider string, options map[string] interface{}) {
-	pbg.options[provider] = options;
+// Check whether a provider declares options it can't run without
+func lockedNeedsOptions(name string) bool {
+	spec, ok := PBGProviderSpecs[name]
+
+	if !ok {
+		return false
+	}
+
+	for _, option := range spec.Options {
+		if option.Required {
+			return true
+		}
+	}
+
+	return false
 }
 
-// Execute the currently installed providers 
-func ExecuteProviders(pbg *ProgramBehaviorGraph, whitelist []string) {
-	roots := getProviderRoots()
+// Compute the order providers will be executed in. Whitelisted providers pull
+// in their dependencies, and blacklisted ones take their dependents with them,
+// unless the filter allows reusing data already in the database. Providers
+// with required options are left out unless configured or whitelisted. Fails
+// on missing or cyclic dependencies.
+func PlanProviders(pbg *ProgramBehaviorGraph, filter PBGProviderFilter) ([]string, error) {
+	PBGProviderListMutex.Lock();
+	defer PBGProviderListMutex.Unlock();
+
+	plan, err := lockedPlanProviders()
 
-	if len(roots) == 0 {
-		panic("No root providers!");
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("Found %s are root providers\n", roots)
+	whitelist := cleanNames(filter.Whitelist)
+	blacklist := cleanNames(filter.Blacklist)
 
-	providerQueue := make(PriorityQueue, len(PBGProviderList));
+	if err := lockedCheckNames(whitelist, "whitelist"); err != nil {
+		return nil, err
+	}
 
-	i := 0
+	if err := lockedCheckNames(blacklist, "blacklist"); err != nil {
+		return nil, err
+	}
+
+	ran := make(map[string] bool)
+
+	reusable := func(name string) bool {
+		if !filter.Reuse || pbg == nil {
+			return false
+		}
+
+		if _, ok := ran[name]; !ok {
+			ran[name] = pbg.ProviderRan(name)
+		}
 
-	// Load the providers into a priority queue via their distance to roots.
-	for dep, _ := range PBGProviderList {
-		maxRoot := -1
+		return ran[name]
+	}
 
-		// Find the longest distance to any of the roots
-		for _, root := range roots {
-			dist := getDepDistance(dep, root) + 1;
+	selected := make(map[string] bool)
+	requested := make(map[string] bool)
 
-			if dist > maxRoot {
-				maxRoot = dist;
-			} 
+	if len(whitelist) == 0 {
+		for _, name := range plan {
+			selected[name] = true
 		}
+	} else {
+		var require func(name string)
+
+		require = func(name string) {
+			if selected[name] {
+				return
+			}
+
+			selected[name] = true
 
-		if maxRoot == -1 && len(PBGProviderBackDepList[dep]) > 0 {
-			panic(fmt.Sprintf("no path to any roots from %s", dep));
+			for _, dep := range PBGProviderBackDepList[name] {
+				if reusable(dep) {
+					log.Printf("Reusing existing output of %s for %s\n", dep, name)
+					continue
+				}
+
+				require(dep)
+			}
 		}
 
-		log.Printf("Found %s has %d hops to root (%d dependencies)\n", dep, maxRoot, len(PBGProviderBackDepList[dep]));
+		for _, name := range whitelist {
+			requested[name] = true
+			require(name)
+		}
+	}
 
-		providerQueue[i] = &Item{
-			value: dep,
-			priority: maxRoot,
-			index: i,
-		};
+	excluded := make(map[string] bool)
 
-		i++;
+	for _, name := range blacklist {
+		excluded[name] = true
 	}
 
-	heap.Init(&providerQueue);
+	// Walking in plan order carries exclusions down to every dependent
+	filtered := make([]string, 0, len(selected))
 
-	// Execute the providers by lowest distance order. This means 
-	// roots first and then their immediate dependents, etc.
-	for providerQueue.Len() > 0 {
-		dep := heap.Pop(&providerQueue).(*Item).value;
-		opt, _ := pbg.options[dep];
+	for _, name := range plan {
+		if !selected[name] {
+			continue
+		}
 
-		if len(whitelist) > 0 && whitelist[0] != "" {
-			found := false
+		if excluded[name] {
+			log.Printf("Skipping stage %s for being blacklisted\n", name)
+			continue
+		}
 
-			for _, obj := range whitelist {
-				if obj == dep {
-					found = true
-					break
-				}
+		// Providers that can't do anything without options are only planned
+		// when configured or asked for, in which case validation complains
+		if !requested[name] && pbg != nil && lockedNeedsOptions(name) {
+			if _, ok := pbg.options[name]; !ok {
+				log.Printf("Skipping stage %s for not being configured\n", name)
+				excluded[name] = true
+				continue
 			}
+		}
 
-			if ( !found ) {
-				log.Printf("Skipping stage %s for not being in whitelist (%d)...\n", dep, len(whitelist));
-				continue
+		missing := ""
+
+		for _, dep := range PBGProviderBackDepList[name] {
+			if excluded[dep] && !reusable(dep) {
+				missing = dep
+				break
 			}
-		} else {
-			log.Printf("Whitelist: %s (%d)\n", whitelist, sort.SearchStrings(whitelist, dep))
 		}
 
-		log.Printf("Executing %s...\n", dep);
-		start := time.Now()
+		if missing != "" {
+			if requested[name] {
+				return nil, fmt.Errorf("provider %s needs %s, which is excluded and has no output to reuse", name, missing)
+			}
 
-		if resv, ok := opt["reservoir"]; ok {
-			resvSize := int(resv.(float64))
-			pbg.SetReservoir(resvSize)
+			log.Printf("Skipping stage %s since it depends on excluded %s\n", name, missing)
+			excluded[name] = true
+			continue
 		}
 
-		// Execute the provider
-		log.SetPrefix("[" + strings.ToUpper(dep) + "] ")
-		PBGProviderList[dep](pbg, opt);
-		log.SetPrefix("[PBG] ")
+		filtered = append(filtered, name)
+	}
+
+	return filtered, nil
+}
+
+// Identity of a provider, used to record what has been run
+func ProviderID(name string) quad.IRI {
+	return NodeID("provider", name)
+}
+
+// Node the record of a provider having run is kept on. Run-scoped providers
+// get a record per run, on the label of their output in it.
+func providerRecordID(provider string, run string) quad.IRI {
+	if PBGProviderRunScoped[provider] {
+		return RunProviderLabel(run, provider)
+	}
+
+	return ProviderID(provider)
+}
+
+// Predicates a provider's record is made of
+var providerRecordPredicates = map[string] bool{
+	"provider-finished": true,
+	"provider-duration": true,
+	"provider-fingerprint": true,
+}
+
+// Remove the record of a provider having run in the current run
+func (pbg *ProgramBehaviorGraph) forgetProviderRan(provider string) error {
+	stale, err := pbg.collectQuads(quad.Subject, providerRecordID(provider, pbg.run), func(q quad.Quad) bool {
+		return q.Label == nil && providerRecordPredicates[nativeString(q.Predicate)]
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return pbg.removeQuads(stale)
+}
+
+// Record that a provider finished writing its output, along with how long it
+// took and the fingerprint of the inputs it was given. An earlier record of
+// the same provider and run is replaced.
+func (pbg *ProgramBehaviorGraph) markProviderRan(provider string, fingerprint string, elapsed time.Duration) {
+	if err := pbg.forgetProviderRan(provider); err != nil {
+		pbg.fail(err)
+		return
+	}
+
+	record := providerRecordID(provider, pbg.run)
+
+	pbg.AddRelationValue(record, "provider-finished", time.Now().Format(time.RFC3339))
+	pbg.AddRelationValue(record, "provider-duration", elapsed.Round(time.Millisecond).String())
+
+	if fingerprint != "" {
+		pbg.AddRelationValue(record, "provider-fingerprint", fingerprint)
+	}
+}
+
+// Runs a provider recorded output into, a single empty one for providers
+// that aren't run-scoped
+func (pbg *ProgramBehaviorGraph) providerRuns(provider string) ([]string, error) {
+	if !PBGProviderRunScoped[provider] {
+		return []string{ "" }, nil
+	}
+
+	return pbg.V(ProviderID(provider)).Out("provider-run").Strings()
+}
+
+// What was recorded about a provider having run in a run, nil if it never
+// finished there
+func (pbg *ProgramBehaviorGraph) providerRecord(provider string, run string) (*PBGProviderRecord, error) {
+	record := &PBGProviderRecord{ Name: provider, Run: run }
+	id := providerRecordID(provider, run)
+
+	fields := []struct{
+		predicate string
+		value *string
+	}{
+		{ "provider-finished", &record.Finished },
+		{ "provider-duration", &record.Duration },
+		{ "provider-fingerprint", &record.Fingerprint },
+	}
+
+	for _, field := range fields {
+		values, err := pbg.V(id).Out(field.predicate).Strings()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Records are replaced as a whole, there is at most one value
+		if len(values) > 0 {
+			*field.value = values[0]
+		}
+	}
+
+	if record.Finished == "" {
+		return nil, nil
+	}
+
+	return record, nil
+}
+
+// Check whether a provider has already written its output to the database,
+// in any run for run-scoped providers
+func (pbg *ProgramBehaviorGraph) ProviderRan(provider string) bool {
+	runs, err := pbg.providerRuns(provider)
+
+	if err != nil {
+		return false
+	}
+
+	for _, run := range runs {
+		if record, err := pbg.providerRecord(provider, run); err == nil && record != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (pbg *ProgramBehaviorGraph) SetProviderOptions(provider string, options map[string] interface{}) {
+	pbg.options[provider] = options;
+}
+
+// Choose what ExecuteProviders does when a provider fails
+func (pbg *ProgramBehaviorGraph) SetErrorPolicy(policy PBGErrorPolicy) {
+	pbg.errorPolicy = policy
+}
+
+// Limit how many providers ExecuteProviders runs at once. Zero or less means
+// one per CPU.
+func (pbg *ProgramBehaviorGraph) SetWorkers(count int) {
+	pbg.workers = count
+}
+
+// Run a single provider against its own view of the graph. Panics are turned
+// into errors so one broken provider can't take the process down.
+func executeProvider(pbg *ProgramBehaviorGraph, dep string) (err error) {
+	opt, _ := pbg.options[dep];
+	view := pbg.providerView(dep)
+
+	log.Printf("Executing %s...\n", dep);
+	start := time.Now()
+
+	// Taken before running in case the provider touches its own inputs
+	fingerprint, fpErr := view.providerFingerprint(dep)
+
+	if fpErr != nil {
+		log.Printf("Failed to fingerprint the inputs of %s (%v), it will rerun on update\n", dep, fpErr)
+	}
+
+	if resv, ok := opt["reservoir"].(int); ok {
+		view.SetReservoir(resv)
+	}
+
+	// Output describing an execution goes under the run's label
+	if PBGProviderRunScoped[dep] {
+		view.beginRun(dep)
+		log.Printf("Recording %s into run %s\n", dep, view.Run())
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+
+		view.endRun()
 
 		// Disable reservoir and flush if possible
-		pbg.SetReservoir(0)
+		view.SetReservoir(0)
+
+		if err == nil {
+			err = view.Err()
+		}
+
+		elapsed := time.Now().Sub(start)
 
-		end := time.Now()
-		elapsed := end.Sub(start)
+		// Only complete output counts as having run
+		if err == nil {
+			view.markProviderRan(dep, fingerprint, elapsed)
+			err = view.Err()
+		}
 
+		if err != nil {
+			log.Printf("Failed %s after %s: %v", dep, elapsed.String(), err)
+		} else {
+			log.Printf("Finished %s in %s", dep, elapsed.String())
+		}
+	}()
 
-		log.Printf("Finished %s in %s", dep, elapsed.String())
+	// Execute the provider
+	return PBGProviderList[dep](view, opt);
+}
+
+// Execute the currently installed providers. A provider starts as soon as all
+// of its planned dependencies have finished, with up to the configured number
+// of workers running side by side. Failures are handled according to the
+// error policy and reported as a *PBGExecutionError.
+func ExecuteProviders(pbg *ProgramBehaviorGraph, filter PBGProviderFilter) error {
+	plan, err := PlanProviders(pbg, filter)
+
+	if err != nil {
+		return err
 	}
+
+	if err := ValidateOptions(pbg, plan); err != nil {
+		return err
+	}
+
+	log.Printf("Execution plan: %s\n", strings.Join(plan, " -> "))
+
+	return ExecutePlan(pbg, plan)
 }
 
+// Outcome of a provider run by ExecutePlan
+type providerResult struct {
+	provider string
+	err error
+}
 
-func RegisterProvider(name string, prov PBGProvider, deps ...string) {
-	// Unsure if this is going to be called before we init so just try anyway
-	initProviderObjects();
+// Run the providers of a plan from PlanProviders, as ExecuteProviders does,
+// for callers that want to show or check the plan first. The plan has to have
+// passed ValidateOptions. A provider starts as soon as all of its dependencies
+// within the plan have finished.
+func ExecutePlan(pbg *ProgramBehaviorGraph, plan []string) error {
+	workers := pbg.workers
 
-	PBGProviderListMutex.Lock();
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	// Concurrent run-scoped providers have to agree on the run they record
+	if pbg.run == "" {
+		pbg.run = defaultRunName()
+	}
 
-	if PBGProviderList[name] != nil {
-		panic("name already registered: " + name);
+	// Only dependencies that are part of this plan need to be waited on
+	planned := make(map[string] bool)
+
+	for _, dep := range plan {
+		planned[dep] = true
 	}
 
-	PBGProviderList[name] = prov;
-	PBGProviderBackDepList[name] = deps;
+	remaining := make(map[string] int)
+	ready := make([]string, 0)
 
-	for _, dep := range deps {
-		if PBGProviderForwardDepList[dep] != nil {
-			PBGProviderForwardDepList[dep] = make([]string, 0);
+	for _, dep := range plan {
+		for _, upstream := range PBGProviderBackDepList[dep] {
+			if planned[upstream] {
+				remaining[dep] += 1
+			}
 		}
 
-		PBGProviderForwardDepList[dep] = append(PBGProviderForwardDepList[dep], name);
+		if remaining[dep] == 0 {
+			ready = append(ready, dep)
+		}
 	}
 
-	PBGProviderListMutex.Unlock();
+	done := make(chan providerResult)
+	started := make(map[string] bool)
+	failures := &PBGExecutionError{}
+	aborted := false
+	running := 0
+
+	for {
+		for len(ready) > 0 && running < workers && !aborted {
+			dep := ready[0]
+			ready = ready[1:]
+			running += 1
+			started[dep] = true
+
+			go func(dep string) {
+				done <- providerResult{ dep, executeProvider(pbg, dep) }
+			}(dep)
+		}
+
+		// Either everything ran or nothing else can start
+		if running == 0 {
+			break
+		}
+
+		result := <-done
+		running -= 1
+
+		if result.err != nil {
+			failures.Failed = append(failures.Failed, &PBGProviderError{ result.provider, result.err })
+
+			// Dependents never become ready, which skips them
+			if pbg.errorPolicy == AbortOnError {
+				aborted = true
+			}
+
+			continue
+		}
+
+		for _, dependent := range PBGProviderForwardDepList[result.provider] {
+			if !planned[dependent] {
+				continue
+			}
+
+			remaining[dependent] -= 1
+
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(failures.Failed) == 0 {
+		return nil
+	}
+
+	for _, dep := range plan {
+		if !started[dep] {
+			failures.Skipped = append(failures.Skipped, dep)
+		}
+	}
+
+	return failures
+}
+
+// Register a provider that doesn't declare its options or predicates
+func RegisterProvider(name string, prov PBGProvider, deps ...string) {
+	RegisterProviderSpec(PBGProviderSpec{ Name: name, Run: prov, Deps: deps })
 }
 
 
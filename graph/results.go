@@ -0,0 +1,228 @@
+package graph
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/cayleygraph/cayley/quad"
+	"github.com/cayleygraph/cayley/query/gizmo"
+)
+
+// Column holding values emitted with g.Emit
+const ValueColumn = "value"
+
+// Output formats understood by WriteRows
+var PBGOutputFormats = []string{ "json", "jsonl", "csv", "tsv", "nquads" }
+
+// One query result with every tag it carries. Values emitted by the query
+// rather than found in the graph are kept in Value.
+type PBGQueryRow struct {
+	Tags map[string] quad.Value
+	Value interface{}
+}
+
+// Execute a query and return one page of its results along with their tags
+func (pbg *ProgramBehaviorGraph) QueryRows(qu string, opts PBGQueryOptions) ([]PBGQueryRow, PBGQueryPage, error) {
+	var rows []PBGQueryRow
+
+	page, err := pbg.executeQuery(qu, opts, func(data *gizmo.Result) error {
+		row := PBGQueryRow{ Tags: make(map[string] quad.Value, len(data.Tags)), Value: data.Val }
+
+		for tag, ref := range data.Tags {
+			row.Tags[tag] = pbg.store.NameOf(ref)
+		}
+
+		rows = append(rows, row)
+		return nil
+	})
+
+	if err != nil {
+		return nil, page, err
+	}
+
+	return rows, page, nil
+}
+
+// Columns covering every row, the result tag first and emitted values last
+func rowColumns(rows []PBGQueryRow) []string {
+	seen := make(map[string] bool)
+	tags := make([]string, 0)
+	values := false
+
+	for _, row := range rows {
+		for tag := range row.Tags {
+			if !seen[tag] && tag != gizmo.TopResultTag {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+
+		values = values || row.Value != nil
+	}
+
+	sort.Strings(tags)
+
+	columns := make([]string, 0, len(tags) + 2)
+
+	for _, row := range rows {
+		if _, ok := row.Tags[gizmo.TopResultTag]; ok {
+			columns = append(columns, gizmo.TopResultTag)
+			break
+		}
+	}
+
+	columns = append(columns, tags...)
+
+	if values {
+		columns = append(columns, ValueColumn)
+	}
+
+	return columns
+}
+
+// Native form of a graph value for JSON, keeping numbers and booleans as such
+func jsonValue(v quad.Value) interface{} {
+	switch v := v.(type) {
+	case quad.Int:
+		return int64(v)
+	case quad.Float:
+		return float64(v)
+	case quad.Bool:
+		return bool(v)
+	}
+
+	return nativeString(v)
+}
+
+// Text of a cell in a CSV or TSV row
+func cellValue(row PBGQueryRow, column string) (string, error) {
+	if column == ValueColumn {
+		switch val := row.Value.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return val, nil
+		}
+
+		// Objects and lists emitted by the query are kept readable as JSON
+		encoded, err := json.Marshal(row.Value)
+		return string(encoded), err
+	}
+
+	return nativeString(row.Tags[column]), nil
+}
+
+// Object of a JSON row, with a field per tag
+func jsonRow(row PBGQueryRow) map[string] interface{} {
+	object := make(map[string] interface{}, len(row.Tags) + 1)
+
+	for tag, val := range row.Tags {
+		object[tag] = jsonValue(val)
+	}
+
+	if row.Value != nil {
+		object[ValueColumn] = row.Value
+	}
+
+	return object
+}
+
+func (row PBGQueryRow) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonRow(row))
+}
+
+// Quad described by a row tagged with subject, predicate, object and
+// optionally label
+func rowQuad(row PBGQueryRow) (quad.Quad, error) {
+	for _, tag := range []string{ "subject", "predicate", "object" } {
+		if row.Tags[tag] == nil {
+			return quad.Quad{}, fmt.Errorf("nquads output needs results tagged subject, predicate and object")
+		}
+	}
+
+	return quad.Quad{
+		Subject: row.Tags["subject"],
+		Predicate: row.Tags["predicate"],
+		Object: row.Tags["object"],
+		Label: row.Tags["label"],
+	}, nil
+}
+
+// Write query results in one of PBGOutputFormats
+func WriteRows(w io.Writer, format string, rows []PBGQueryRow) error {
+	switch format {
+	case "json":
+		objects := make([]map[string] interface{}, 0, len(rows))
+
+		for _, row := range rows {
+			objects = append(objects, jsonRow(row))
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(objects)
+	case "jsonl":
+		encoder := json.NewEncoder(w)
+
+		for _, row := range rows {
+			if err := encoder.Encode(jsonRow(row)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case "csv", "tsv":
+		writer := csv.NewWriter(w)
+
+		if format == "tsv" {
+			writer.Comma = '\t'
+		}
+
+		columns := rowColumns(rows)
+
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			record := make([]string, len(columns))
+
+			for i, column := range columns {
+				cell, err := cellValue(row, column)
+
+				if err != nil {
+					return err
+				}
+
+				record[i] = cell
+			}
+
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		return writer.Error()
+	case "nquads":
+		for _, row := range rows {
+			q, err := rowQuad(row)
+
+			if err != nil {
+				return err
+			}
+
+			if _, err := fmt.Fprintln(w, toRDF(q).NQuad()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown output format %s", format)
+}
@@ -0,0 +1,183 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Providers whose output describes one execution of the program rather than
+// the program itself. Their quads are labeled with the run they belong to
+// rather than the provider alone.
+var PBGProviderRunScoped map[string] bool;
+
+// Register a provider whose output belongs to a single run
+func RegisterRunProvider(name string, prov PBGProvider, deps ...string) {
+	RegisterProviderSpec(PBGProviderSpec{ Name: name, Run: prov, Deps: deps, RunScoped: true })
+}
+
+// Identity of a named run
+func RunLabel(name string) quad.IRI {
+	return NodeID("run", name)
+}
+
+// Label of the quads a run-scoped provider wrote during a run. Every label of
+// a run shares the run's identity as its prefix.
+func RunProviderLabel(run string, provider string) quad.IRI {
+	return ChildID(RunLabel(run), "provider", provider)
+}
+
+// Check whether a quad label belongs to any run
+func isRunLabel(label quad.Value) bool {
+	iri, ok := label.(quad.IRI)
+	return ok && strings.HasPrefix(string(iri), string(NodeID("run", "")))
+}
+
+// Name given to runs the configuration didn't name
+func defaultRunName() string {
+	return time.Now().Format("run-20060102-150405")
+}
+
+// Name the execution that run-scoped providers record into
+func (pbg *ProgramBehaviorGraph) SetRun(name string) {
+	pbg.run = name
+}
+
+// Name of the current run, empty if none was set yet
+func (pbg *ProgramBehaviorGraph) Run() string {
+	return pbg.run
+}
+
+// Start writing the output of a run-scoped provider under the current run,
+// picking a timestamped name if none was configured.
+func (pbg *ProgramBehaviorGraph) beginRun(provider string) {
+	if pbg.run == "" {
+		pbg.run = defaultRunName()
+	}
+
+	runId := RunLabel(pbg.run)
+
+	// Run metadata stays unlabeled so runs can be listed from any context
+	pbg.label = nil
+	pbg.AddRelationValue(runId, "run-name", pbg.run)
+	pbg.AddRelationValue(runId, "run-provider", provider)
+	pbg.AddRelationValue(ProviderID(provider), "provider-run", pbg.run)
+
+	pbg.label = RunProviderLabel(pbg.run, provider)
+}
+
+// Stop labeling writes, used once a provider is done producing output
+func (pbg *ProgramBehaviorGraph) endRun() {
+	pbg.label = nil
+}
+
+// Check whether a quad label was written during the given run
+func inRun(label quad.Value, run string) bool {
+	iri, ok := label.(quad.IRI)
+	return ok && strings.HasPrefix(string(iri), string(RunLabel(run)) + "/")
+}
+
+// List the names of every run recorded in the database
+func (pbg *ProgramBehaviorGraph) Runs() ([]string, error) {
+	return pbg.V().Out("run-name").Strings()
+}
+
+// Every label the quads of a run were written under, one per provider
+func (pbg *ProgramBehaviorGraph) RunLabels(run string) ([]quad.IRI, error) {
+	values, err := pbg.V(RunLabel(run)).Out("run-provider").Strings()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("unknown run %s", run)
+	}
+
+	labels := make([]quad.IRI, 0, len(values))
+
+	for _, val := range values {
+		labels = append(labels, RunProviderLabel(run, val))
+	}
+
+	return labels, nil
+}
+
+// Collect the subject/object pairs of a predicate recorded under one run
+func (pbg *ProgramBehaviorGraph) runEdges(run string, predicate string) (map[string] PBGTriplet, error) {
+	ctx, cancel, timeout := pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	edges := make(map[string] PBGTriplet)
+
+	labels, err := pbg.RunLabels(run)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, label := range labels {
+		ref := pbg.store.ValueOf(label)
+
+		if ref == nil {
+			continue
+		}
+
+		it := pbg.store.QuadIterator(quad.Label, ref)
+
+		err := graph.Iterate(ctx, it).Each(func(ref graph.Ref) {
+			q := pbg.store.Quad(ref)
+
+			if nativeString(q.Predicate) != predicate {
+				return
+			}
+
+			key := quad.StringOf(q.Subject) + "\t" + quad.StringOf(q.Object)
+			edges[key] = PBGTriplet { q.Subject, q.Predicate, q.Object }
+		})
+
+		it.Close()
+
+		if err != nil {
+			return nil, queryError(ctx, timeout, err)
+		}
+	}
+
+	return edges, nil
+}
+
+// Compare the edges of a predicate between two runs, returning the edges only
+// seen in the first run and those only seen in the second.
+func (pbg *ProgramBehaviorGraph) CompareRuns(a string, b string, predicate string) ([]PBGTriplet, []PBGTriplet, error) {
+	edgesA, err := pbg.runEdges(a, predicate)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	edgesB, err := pbg.runEdges(b, predicate)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	onlyA := make([]PBGTriplet, 0)
+	onlyB := make([]PBGTriplet, 0)
+
+	for key, triplet := range edgesA {
+		if _, ok := edgesB[key]; !ok {
+			onlyA = append(onlyA, triplet)
+		}
+	}
+
+	for key, triplet := range edgesB {
+		if _, ok := edgesA[key]; !ok {
+			onlyB = append(onlyB, triplet)
+		}
+	}
+
+	return onlyA, onlyB, nil
+}
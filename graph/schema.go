@@ -0,0 +1,449 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Kinds of values a provider option can hold. Validated options are converted
+// to string, []string, int and bool respectively.
+type PBGOptionType int
+
+const (
+	OptionString PBGOptionType = iota
+	OptionStringList
+	OptionInt
+	OptionBool
+)
+
+func (t PBGOptionType) String() string {
+	switch t {
+	case OptionString:
+		return "string"
+	case OptionStringList:
+		return "list of strings"
+	case OptionInt:
+		return "integer"
+	case OptionBool:
+		return "boolean"
+	}
+
+	return fmt.Sprintf("PBGOptionType(%d)", int(t))
+}
+
+// An option a provider accepts in its configuration section
+type PBGOption struct {
+	Name string
+	Type PBGOptionType
+
+	// Must be given whenever the provider has a configuration section
+	Required bool
+
+	// Used when the option isn't configured, nil for none
+	Default interface{}
+
+	Description string
+}
+
+// Kinds of values found at either end of a predicate, used to type the
+// columns of exported Datalog relations
+type PBGValueKind int
+
+const (
+	ValueUnknown PBGValueKind = iota
+	ValueSymbol
+	ValueNumber
+)
+
+func (k PBGValueKind) String() string {
+	switch k {
+	case ValueUnknown:
+		return "unknown"
+	case ValueSymbol:
+		return "symbol"
+	case ValueNumber:
+		return "number"
+	}
+
+	return fmt.Sprintf("PBGValueKind(%d)", int(k))
+}
+
+// Kinds of the subjects and objects of a predicate
+type PBGPredicateType struct {
+	Subject PBGValueKind
+	Object PBGValueKind
+}
+
+// Everything a provider declares about itself
+type PBGProviderSpec struct {
+	Name string
+	Run PBGProvider
+
+	// Providers whose output this one reads
+	Deps []string
+
+	// Output describes one execution of the program, see RegisterRunProvider
+	RunScoped bool
+
+	// Accepted options, nil if the provider doesn't declare any and takes
+	// whatever it is given
+	Options []PBGOption
+
+	// Predicates written and read by the provider, nil if undeclared
+	Produces []string
+	Consumes []string
+
+	// Kinds of values of produced predicates, where known
+	Types map[string] PBGPredicateType
+}
+
+// Options every provider accepts, handled while executing it
+var PBGCommonOptions = []PBGOption {
+	{
+		Name: "reservoir",
+		Type: OptionInt,
+		Description: "keep a random sample of this many quads instead of all of them",
+	},
+}
+
+// Declarations of every registered provider, guarded by PBGProviderListMutex
+var PBGProviderSpecs map[string] *PBGProviderSpec;
+
+// Register a provider along with its declarations
+func RegisterProviderSpec(spec PBGProviderSpec) {
+	// Unsure if this is going to be called before we init so just try anyway
+	initProviderObjects();
+
+	PBGProviderListMutex.Lock();
+	defer PBGProviderListMutex.Unlock();
+
+	if PBGProviderList[spec.Name] != nil {
+		panic("name already registered: " + spec.Name);
+	}
+
+	PBGProviderSpecs[spec.Name] = &spec
+	PBGProviderList[spec.Name] = spec.Run;
+	PBGProviderBackDepList[spec.Name] = spec.Deps;
+	PBGProviderRunScoped[spec.Name] = spec.RunScoped
+
+	for _, dep := range spec.Deps {
+		if PBGProviderForwardDepList[dep] == nil {
+			PBGProviderForwardDepList[dep] = make([]string, 0);
+		}
+
+		PBGProviderForwardDepList[dep] = append(PBGProviderForwardDepList[dep], spec.Name);
+	}
+}
+
+// Declarations of every registered provider, sorted by name
+func ProviderSpecs() []PBGProviderSpec {
+	PBGProviderListMutex.Lock();
+	defer PBGProviderListMutex.Unlock();
+
+	specs := make([]PBGProviderSpec, 0, len(PBGProviderSpecs))
+
+	for _, spec := range PBGProviderSpecs {
+		specs = append(specs, *spec)
+	}
+
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+
+	return specs
+}
+
+// Value kinds declared for every predicate. A side providers disagree on is
+// left unknown.
+func PredicateTypes() map[string] PBGPredicateType {
+	PBGProviderListMutex.Lock();
+	defer PBGProviderListMutex.Unlock();
+
+	types := make(map[string] PBGPredicateType)
+
+	for _, spec := range PBGProviderSpecs {
+		for predicate, declared := range spec.Types {
+			known, ok := types[predicate]
+
+			if !ok {
+				types[predicate] = declared
+				continue
+			}
+
+			if known.Subject != declared.Subject {
+				known.Subject = ValueUnknown
+			}
+
+			if known.Object != declared.Object {
+				known.Object = ValueUnknown
+			}
+
+			types[predicate] = known
+		}
+	}
+
+	return types
+}
+
+// Check that every predicate a provider consumes is produced by one of the
+// providers it depends on. Skipped when a dependency didn't declare its
+// output, since nothing can be said about it.
+func lockedCheckPredicates(name string) error {
+	spec := PBGProviderSpecs[name]
+
+	if spec == nil || len(spec.Consumes) == 0 {
+		return nil
+	}
+
+	produced := make(map[string] bool)
+	seen := make(map[string] bool)
+	pending := append([]string{}, spec.Deps...)
+
+	for len(pending) > 0 {
+		dep := pending[0]
+		pending = pending[1:]
+
+		if seen[dep] {
+			continue
+		}
+
+		seen[dep] = true
+		depSpec := PBGProviderSpecs[dep]
+
+		if depSpec == nil || depSpec.Produces == nil {
+			return nil
+		}
+
+		for _, predicate := range depSpec.Produces {
+			produced[predicate] = true
+		}
+
+		pending = append(pending, depSpec.Deps...)
+	}
+
+	for _, predicate := range spec.Consumes {
+		if !produced[predicate] {
+			return fmt.Errorf("provider %s consumes %s, which none of its dependencies produce", name, predicate)
+		}
+	}
+
+	return nil
+}
+
+// Edit distance between two option names, ignoring case
+func optionDistance(a string, b string) int {
+	a = strings.ToLower(a)
+	b = strings.ToLower(b)
+
+	prev := make([]int, len(b) + 1)
+	cur := make([]int, len(b) + 1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+
+			if a[i - 1] == b[j - 1] {
+				cost = 0
+			}
+
+			cur[j] = prev[j - 1] + cost
+
+			if prev[j] + 1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+
+			if cur[j - 1] + 1 < cur[j] {
+				cur[j] = cur[j - 1] + 1
+			}
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// Closest known name to a misspelled one, empty if nothing is close
+func suggestOption(name string, options []PBGOption) string {
+	best := ""
+	bestDistance := 4
+
+	for _, option := range options {
+		distance := optionDistance(name, option.Name)
+
+		if distance < bestDistance {
+			best = option.Name
+			bestDistance = distance
+		}
+	}
+
+	return best
+}
+
+// Convert a decoded configuration value to the option's Go type
+func convertOption(option PBGOption, value interface{}) (interface{}, error) {
+	switch option.Type {
+	case OptionString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case OptionStringList:
+		switch value := value.(type) {
+		case []string:
+			return value, nil
+		case []interface{}:
+			list := make([]string, 0, len(value))
+
+			for i, entry := range value {
+				s, ok := entry.(string)
+
+				if !ok {
+					return nil, fmt.Errorf("entry %d of %s should be a string, got %T", i, option.Name, entry)
+				}
+
+				list = append(list, s)
+			}
+
+			return list, nil
+		}
+	case OptionInt:
+		switch value := value.(type) {
+		case int:
+			return value, nil
+		case float64:
+			if value == math.Trunc(value) {
+				return int(value), nil
+			}
+		}
+	case OptionBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s should be a %s, got %v", option.Name, option.Type, value)
+}
+
+// Check the configured options against what the providers declare. Unknown
+// sections and keys, mistyped values and missing required options of planned
+// providers are all reported at once, configured or not. Valid options are converted to their
+// declared types, and defaults are filled in for planned providers.
+func ValidateOptions(pbg *ProgramBehaviorGraph, plan []string) error {
+	PBGProviderListMutex.Lock();
+	defer PBGProviderListMutex.Unlock();
+
+	problems := make([]string, 0)
+
+	planned := make(map[string] bool)
+
+	for _, name := range plan {
+		planned[name] = true
+
+		if err := lockedCheckPredicates(name); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	sections := make([]string, 0, len(pbg.options))
+
+	for section := range pbg.options {
+		sections = append(sections, section)
+	}
+
+	for name := range planned {
+		if _, ok := pbg.options[name]; !ok {
+			sections = append(sections, name)
+		}
+	}
+
+	sort.Strings(sections)
+
+	for _, section := range sections {
+		spec, ok := PBGProviderSpecs[section]
+
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown provider %s", section))
+			continue
+		}
+
+		opt := pbg.options[section]
+
+		// Providers without a schema only get the common options checked,
+		// whatever else they are given is passed on untouched
+		options := append(append([]PBGOption{}, PBGCommonOptions...), spec.Options...)
+		known := make(map[string] PBGOption)
+
+		for _, option := range options {
+			known[option.Name] = option
+		}
+
+		keys := make([]string, 0, len(opt))
+
+		for key := range opt {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			option, ok := known[key]
+
+			if !ok && spec.Options == nil {
+				continue
+			} else if !ok {
+				problem := fmt.Sprintf("%s: unknown option %s", section, key)
+
+				if suggestion := suggestOption(key, options); suggestion != "" {
+					problem += fmt.Sprintf(" (did you mean %s?)", suggestion)
+				}
+
+				problems = append(problems, problem)
+				continue
+			}
+
+			value, err := convertOption(option, opt[key])
+
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", section, err))
+				continue
+			}
+
+			opt[key] = value
+		}
+
+		if !planned[section] {
+			continue
+		}
+
+		for _, option := range options {
+			if _, ok := opt[option.Name]; ok {
+				continue
+			}
+
+			if option.Required {
+				problems = append(problems, fmt.Sprintf("%s: missing required option %s", section, option.Name))
+			} else if option.Default != nil {
+				if opt == nil {
+					opt = make(map[string] interface{})
+					pbg.options[section] = opt
+				}
+
+				opt[option.Name] = option.Default
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package graph
+
+import (
+	"hash/fnv"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Edges of one predicate
+type PBGPredicateStats struct {
+	Predicate string `json:"predicate"`
+	Edges int `json:"edges"`
+	Subjects int `json:"subjects"`
+	Objects int `json:"objects"`
+
+	// Objects by kind of value, see valueKind
+	ObjectKinds map[string] int `json:"object_kinds"`
+}
+
+// What is recorded about a provider having run, once per run for run-scoped
+// providers
+type PBGProviderRecord struct {
+	Name string `json:"name"`
+	Run string `json:"run,omitempty"`
+	Finished string `json:"finished"`
+	Duration string `json:"duration,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Overview of what a database holds
+type PBGStats struct {
+	Quads int `json:"quads"`
+	Nodes int `json:"nodes"`
+
+	// Distinct subjects and objects by kind of value
+	NodeKinds map[string] int `json:"node_kinds"`
+
+	// Sorted by predicate
+	Predicates []PBGPredicateStats `json:"predicates"`
+
+	// Quads per label, unlabeled ones under an empty name
+	Labels map[string] int `json:"labels"`
+
+	Runs []string `json:"runs"`
+	Providers []PBGProviderRecord `json:"providers"`
+}
+
+// Kind of a value as reported by Stats
+func valueKind(v quad.Value) string {
+	switch v.(type) {
+	case quad.IRI:
+		return "entity"
+	case quad.Int:
+		return "number"
+	case quad.String:
+		return "string"
+	case quad.Bool:
+		return "boolean"
+	case quad.BNode:
+		return "blank"
+	}
+
+	return "other"
+}
+
+// Values are counted by hash so that large literals such as section data
+// aren't kept around
+func valueHash(v quad.Value) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(FormatValue(v)))
+
+	return h.Sum64()
+}
+
+type predicateCounter struct {
+	edges int
+	subjects map[uint64] bool
+	objects map[uint64] bool
+	kinds map[string] int
+}
+
+// Providers that ran, with their timings and fingerprints, one record per
+// provider and run
+func (pbg *ProgramBehaviorGraph) ProviderRecords() ([]PBGProviderRecord, error) {
+	prefix := string(NodeID("provider", ""))
+	names := make(map[string] bool)
+
+	// Providers are found through their records, or the runs they recorded
+	// into for run-scoped ones
+	for _, predicate := range []string{ "provider-finished", "provider-run" } {
+		ids, err := pbg.V().Has(predicate).Strings()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			if !strings.HasPrefix(id, prefix) {
+				continue
+			}
+
+			name, err := url.PathUnescape(id[len(prefix):])
+
+			if err != nil {
+				name = id[len(prefix):]
+			}
+
+			names[name] = true
+		}
+	}
+
+	result := make([]PBGProviderRecord, 0, len(names))
+
+	for name := range names {
+		runs, err := pbg.providerRuns(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, run := range runs {
+			record, err := pbg.providerRecord(name, run)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if record != nil {
+				result = append(result, *record)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+
+		return result[i].Run < result[j].Run
+	})
+
+	return result, nil
+}
+
+// Count what the database holds in a single pass over its quads
+func (pbg *ProgramBehaviorGraph) Stats() (*PBGStats, error) {
+	ctx, cancel, timeout := pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	stats := &PBGStats{
+		NodeKinds: make(map[string] int),
+		Predicates: make([]PBGPredicateStats, 0),
+		Labels: make(map[string] int),
+	}
+
+	nodes := make(map[uint64] bool)
+	predicates := make(map[string] *predicateCounter)
+
+	countNode := func(hash uint64, v quad.Value) {
+		if !nodes[hash] {
+			nodes[hash] = true
+			stats.NodeKinds[valueKind(v)] += 1
+		}
+	}
+
+	it := pbg.store.QuadsAllIterator()
+	defer it.Close()
+
+	err := graph.Iterate(ctx, it).Each(func(ref graph.Ref) {
+		q := pbg.store.Quad(ref)
+		stats.Quads += 1
+
+		label := ""
+
+		if q.Label != nil {
+			label = nativeString(q.Label)
+		}
+
+		stats.Labels[label] += 1
+
+		predicate := nativeString(q.Predicate)
+		counter, ok := predicates[predicate]
+
+		if !ok {
+			counter = &predicateCounter{
+				subjects: make(map[uint64] bool),
+				objects: make(map[uint64] bool),
+				kinds: make(map[string] int),
+			}
+			predicates[predicate] = counter
+		}
+
+		subject, object := valueHash(q.Subject), valueHash(q.Object)
+
+		counter.edges += 1
+		counter.subjects[subject] = true
+		counter.objects[object] = true
+		counter.kinds[valueKind(q.Object)] += 1
+
+		countNode(subject, q.Subject)
+		countNode(object, q.Object)
+	})
+
+	if err != nil {
+		return nil, queryError(ctx, timeout, err)
+	}
+
+	stats.Nodes = len(nodes)
+
+	for predicate, counter := range predicates {
+		stats.Predicates = append(stats.Predicates, PBGPredicateStats{
+			Predicate: predicate,
+			Edges: counter.edges,
+			Subjects: len(counter.subjects),
+			Objects: len(counter.objects),
+			ObjectKinds: counter.kinds,
+		})
+	}
+
+	sort.Slice(stats.Predicates, func(i, j int) bool {
+		return stats.Predicates[i].Predicate < stats.Predicates[j].Predicate
+	})
+
+	if stats.Runs, err = pbg.Runs(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(stats.Runs)
+
+	if stats.Providers, err = pbg.ProviderRecords(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// Bytes a database takes on disk, whether the backend keeps it in a single
+// file like bolt and sqlite or in a directory like leveldb. Backends without a
+// path, such as memstore, take none.
+func DatabaseSize(path string) (int64, error) {
+	if path == "" {
+		return 0, nil
+	}
+
+	var size int64
+
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
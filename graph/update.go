@@ -0,0 +1,229 @@
+package graph
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cayleygraph/cayley/graph"
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Quads removed per transaction when clearing a provider's output
+const removeChunkSize = 10000
+
+// Collect every quad matching a direction/value pair that passes the filter
+func (pbg *ProgramBehaviorGraph) collectQuads(dir quad.Direction, value quad.Value, keep func(quad.Quad) bool) ([]quad.Quad, error) {
+	ctx, cancel, timeout := pbg.queryContext(PBGQueryOptions{})
+	defer cancel()
+
+	quads := make([]quad.Quad, 0)
+
+	ref := pbg.store.ValueOf(value)
+
+	if ref == nil {
+		return quads, nil
+	}
+
+	it := pbg.store.QuadIterator(dir, ref)
+	defer it.Close()
+
+	err := graph.Iterate(ctx, it).Each(func(ref graph.Ref) {
+		q := pbg.store.Quad(ref)
+
+		if keep(q) {
+			quads = append(quads, q)
+		}
+	})
+
+	if err != nil {
+		return nil, queryError(ctx, timeout, err)
+	}
+
+	return quads, nil
+}
+
+// Delete quads from the store in chunks, holding off concurrent writers
+func (pbg *ProgramBehaviorGraph) removeQuads(quads []quad.Quad) error {
+	pbg.writeMu.Lock()
+	defer pbg.writeMu.Unlock()
+
+	for start := 0; start < len(quads); start += removeChunkSize {
+		end := start + removeChunkSize
+
+		if end > len(quads) {
+			end = len(quads)
+		}
+
+		tx := graph.NewTransaction()
+
+		for _, q := range quads[start:end] {
+			tx.RemoveQuad(q)
+		}
+
+		if err := pbg.store.ApplyTransaction(tx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove what a provider wrote the last time it ran, along with the record of
+// it having run. Run-scoped providers only lose their output for the current
+// run; other runs are left for comparison.
+func (pbg *ProgramBehaviorGraph) clearProvider(provider string) error {
+	everything := func(quad.Quad) bool { return true }
+	labels := []quad.Value{ ProviderID(provider) }
+
+	if PBGProviderRunScoped[provider] {
+		labels = append(labels, RunProviderLabel(pbg.run, provider))
+	}
+
+	stale := make([]quad.Quad, 0)
+
+	for _, label := range labels {
+		quads, err := pbg.collectQuads(quad.Label, label, everything)
+
+		if err != nil {
+			return err
+		}
+
+		stale = append(stale, quads...)
+	}
+
+	// Other runs keep their record of the provider having run
+	if PBGProviderRunScoped[provider] {
+		quads, err := pbg.collectQuads(quad.Subject, ProviderID(provider), func(q quad.Quad) bool {
+			return q.Label == nil && nativeString(q.Predicate) == "provider-run" && nativeString(q.Object) == pbg.run
+		})
+
+		if err != nil {
+			return err
+		}
+
+		stale = append(stale, quads...)
+	}
+
+	log.Printf("Removing %d quads previously written by %s\n", len(stale), provider)
+
+	if err := pbg.removeQuads(stale); err != nil {
+		return err
+	}
+
+	return pbg.forgetProviderRan(provider)
+}
+
+// Run that a run-scoped provider of the plan finished recording into most
+// recently, if any
+func (pbg *ProgramBehaviorGraph) lastRun(plan []string) string {
+	last := ""
+	var lastFinished time.Time
+
+	for _, provider := range plan {
+		if !PBGProviderRunScoped[provider] {
+			continue
+		}
+
+		runs, err := pbg.providerRuns(provider)
+
+		if err != nil {
+			continue
+		}
+
+		for _, run := range runs {
+			record, err := pbg.providerRecord(provider, run)
+
+			if err != nil || record == nil {
+				continue
+			}
+
+			finished, err := time.Parse(time.RFC3339, record.Finished)
+
+			if err == nil && (last == "" || finished.After(lastFinished)) {
+				last, lastFinished = run, finished
+			}
+		}
+	}
+
+	return last
+}
+
+// Bring an existing database up to date with the current options. Providers
+// whose inputs changed since they last ran, and everything depending on them,
+// have their previous output removed and are executed again; the rest keep
+// what they already produced. Without a configured run, run-scoped providers
+// update the run they last recorded into.
+func UpdateProviders(pbg *ProgramBehaviorGraph, filter PBGProviderFilter) error {
+	plan, err := PlanProviders(pbg, filter)
+
+	if err != nil {
+		return err
+	}
+
+	// Defaults have to be in place before fingerprinting
+	if err := ValidateOptions(pbg, plan); err != nil {
+		return err
+	}
+
+	if pbg.run == "" {
+		pbg.run = pbg.lastRun(plan)
+	}
+
+	if pbg.run == "" {
+		pbg.run = defaultRunName()
+	}
+
+	dirty := make(map[string] bool)
+	rerun := make([]string, 0)
+
+	for _, provider := range plan {
+		reason := ""
+
+		for _, dep := range PBGProviderBackDepList[provider] {
+			if dirty[dep] {
+				reason = "dependency " + dep + " changed"
+				break
+			}
+		}
+
+		if reason == "" {
+			fingerprint, err := pbg.providerFingerprint(provider)
+
+			if err != nil {
+				return fmt.Errorf("failed to fingerprint the inputs of %s: %v", provider, err)
+			}
+
+			switch stored := pbg.storedFingerprint(provider); stored {
+			case "":
+				reason = "no previous output"
+			case fingerprint:
+				log.Printf("Keeping %s, its inputs are unchanged\n", provider)
+				continue
+			default:
+				reason = "inputs changed"
+			}
+		}
+
+		log.Printf("Updating %s: %s\n", provider, reason)
+
+		dirty[provider] = true
+		rerun = append(rerun, provider)
+	}
+
+	if len(rerun) == 0 {
+		log.Printf("Everything is up to date\n")
+		return nil
+	}
+
+	for _, provider := range rerun {
+		if err := pbg.clearProvider(provider); err != nil {
+			return fmt.Errorf("failed to remove the previous output of %s: %v", provider, err)
+		}
+	}
+
+	log.Printf("Update plan: %s\n", strings.Join(rerun, " -> "))
+
+	return ExecutePlan(pbg, rerun)
+}
@@ -1,29 +1,24 @@
 // Find miss counts
-var misses = g.V().In('miss-address').ToArray();
+var misses = V().In('miss-address').ToArray();
 var counts = {}
 
 for ( var i = 0; i < misses.length; i++ ) {
+	var missAddr = misses[i];
+
 	// Ignore misses from outside TCC
-	if ( misses[i].substring(0, 4) == '0x7f' ) {
+	if ( missAddr >= 0x7f0000000000 ) {
 		continue;
 	}
 
-	var missAddr = misses[i].substring(2);
-	var missLoc = g.V(misses[i]).Out('miss-address').Limit(1).ToArray()[0];
+	var missLoc = V(missAddr).Out('miss-address').Limit(1).ToArray()[0];
 
 	// Ignore instruction misses
 	if ( missAddr == missLoc ) {
 		continue;
 	}
 
-	while ( missAddr.length < 8 ) {
-		missAddr = "0" + missAddr;
-	}
-
-	missAddr = "0x" + missAddr;
-
 	// Make sure we have line data for this
-	if ( g.V().Has("text-at-pc", missAddr).Count() == 0 ) {
+	if ( V().Has("text-at-pc", missAddr).Count() == 0 ) {
 		continue;
 	}
 
@@ -41,7 +36,8 @@ var maxCount = 0;
 
 for ( var addr in counts ) {
 	if ( maxAddr == undefined || counts[addr] > maxCount ) {
-		maxAddr = addr;
+		// Object keys are strings, addresses are numbers in the graph
+		maxAddr = Number(addr);
 		maxCount = counts[addr];
 	}
 }
@@ -51,12 +47,12 @@ if ( !maxAddr ) {
 }
 
 // Get line
-var line = g.V().Has("text-at-pc", maxAddr).Limit(1).ToArray()[0];
+var line = lineAtPC(maxAddr);
 
 if ( !line ) {
 	throw new Error("Failed to find text for " + maxAddr)
 }
 
-g.Emit("Worst address: " +  maxAddr);
+g.Emit("Worst address: 0x" +  maxAddr.toString(16));
 g.Emit("Worst number: " + line);
-g.V(line).Out('line-content').All();
+V(line).Out('line-content').All();
@@ -17,7 +17,7 @@ echo "The following tests will be run: $TESTS"
 echo "The tests will be run for $TEST_ITERS iterations."
 echo "The results are going to be written to $OUTPUTDIR"
 echo "The tests will be written to a $BACKEND database"
-echo "The queries will be executed with $SOUFFLE"
+echo "The queries will be executed with $SOUFFLE, or the built-in engine if it is missing"
 echo "The results will be written to $RESULTS"
 
 read -t 60
@@ -61,7 +61,11 @@ for i in $(seq 1 $TEST_ITERS); do
 		query_time=$(do_time "$PBG project query -db=$db -backend=$BACKEND -datalog=$OUTPUTDIR/datalog_dir/" "$OUTPUTDIR/$name.query.$i.txt")
 
 		echo "Running memory tests"
-		test_time=$(do_time "$SOUFFLE --fact-dir=$OUTPUTDIR/datalog_dir -c -j64 ./queries/mem-test.dl" "$OUTPUTDIR/$name.test.$i.txt")
+		if [ -x "$SOUFFLE" ]; then
+			test_time=$(do_time "$SOUFFLE --fact-dir=$OUTPUTDIR/datalog_dir -c -j64 ./queries/mem-test.dl" "$OUTPUTDIR/$name.test.$i.txt")
+		else
+			test_time=$(do_time "$PBG project query -db=$db -backend=$BACKEND -dl=./queries/mem-test.dl" "$OUTPUTDIR/$name.test.$i.txt")
+		fi
 
 		echo "Saving results"
 		echo -e "$name\t$i\t$create_time\t$query_time\t$test_time" >> $RESULTS
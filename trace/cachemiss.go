@@ -1,30 +1,29 @@
 package trace;
 
 import (
-	"log"
 	"os"
 	"bufio"
 	"pbg/graph"
 	"strings"
 )
 
-func loadCacheMiss(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
+func loadCacheMiss(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
 	trace, ok := opt["cacheMissFile"].(string)
 
 	if !ok {
-		log.Println("No cache miss file found, skipping...")
-		return;
+		pbg.Logger().Println("No cache miss file found, skipping...")
+		return nil
 	}
 
 	file, err := os.Open(trace)
 
 	if err != nil {
-		panic(err);
+		return err;
 	}
 
 	defer file.Close()
 
-	pbg.AddRelationFunc(func(ch chan []string) {
+	pbg.AddRelationFunc(func(ch chan []interface{}) error {
 		idx := 0
 
 		scanner := bufio.NewScanner(file)
@@ -45,17 +44,37 @@ func loadCacheMiss(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{})
 				continue
 			}
 
-			ch <- []string{ parts[0], "miss-address", parts[1] }
-		}
+			pc, err := graph.ParseAddr(parts[0])
+
+			if err != nil {
+				continue
+			}
+
+			addr, err := graph.ParseAddr(parts[1])
 
-		if err := scanner.Err(); err != nil {
-			panic(err)
+			if err != nil {
+				continue
+			}
+
+			ch <- []interface{}{ pc, "miss-address", addr }
 		}
 
-		close(ch)
+		return scanner.Err()
 	})
+
+	return nil
 }
 
 func init() {
-	graph.RegisterProvider("cachemiss", loadCacheMiss, "elf")
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "cachemiss",
+		Run: loadCacheMiss,
+		Deps: []string{ "elf" },
+		RunScoped: true,
+		Options: []graph.PBGOption{
+			{ Name: "cacheMissFile", Type: graph.OptionString, Description: "csv of pc,address cache misses" },
+		},
+		Produces: []string{ "miss-address" },
+		Types: traceTypes("miss-address"),
+	})
 }
\ No newline at end of file
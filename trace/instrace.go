@@ -1,34 +1,33 @@
 package trace;
 
 import (
-	"log"
 	"os"
 	"bufio"
 	"pbg/graph"
 	"strings"
 )
 
-func loadInstrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
+func loadInstrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
 	trace, ok := opt["traceFile"].(string)
 
 	if !ok {
-		log.Println("No instrace file found, skipping...");
-		return;
+		pbg.Logger().Println("No instrace file found, skipping...");
+		return nil
 	}
 
 	file, err := os.Open(trace)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	defer file.Close()
 
 	count := 0
 
-	pbg.AddRelationFunc(func(ch chan []string) {
+	pbg.AddRelationFunc(func(ch chan []interface{}) error {
 		idx := 0
-		var last string
+		var last graph.Addr
 
 		scanner := bufio.NewScanner(file)
 		
@@ -48,24 +47,38 @@ func loadInstrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{})
 				continue
 			}
 
+			pc, err := graph.ParseAddr(parts[0])
+
+			if err != nil {
+				continue
+			}
+
 			if ( idx > 1 ) {
 				count += 1
-				ch <- []string{ last, "next-address", parts[0] }
+				ch <- []interface{}{ last, "next-address", pc }
 			}
 
-			last = parts[0]
+			last = pc
 		}
 
-		if err := scanner.Err(); err != nil {
-			panic(err)
-		}
-
-		close(ch)
+		return scanner.Err()
 	})
 
-	log.Printf("Loaded %d instructions in trace\n", count)
+	pbg.Logger().Printf("Loaded %d instructions in trace\n", count)
+
+	return nil
 }
 
 func init() {
-	graph.RegisterProvider("instrace", loadInstrace, "elf")
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "instrace",
+		Run: loadInstrace,
+		Deps: []string{ "elf" },
+		RunScoped: true,
+		Options: []graph.PBGOption{
+			{ Name: "traceFile", Type: graph.OptionString, Description: "csv of executed pcs from the instrace sample" },
+		},
+		Produces: []string{ "next-address" },
+		Types: traceTypes("next-address"),
+	})
 }
\ No newline at end of file
@@ -1,30 +1,29 @@
 package trace;
 
 import (
-	"log"
 	"os"
 	"bufio"
 	"pbg/graph"
 	"strings"
 )
 
-func loadMemtrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
+func loadMemtrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
 	trace, ok := opt["memTraceFile"].(string)
 
 	if !ok {
-		log.Printf("No memory trace file found, skipping...\n");
-		return;
+		pbg.Logger().Printf("No memory trace file found, skipping...\n");
+		return nil
 	}
 
 	file, err := os.Open(trace)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	defer file.Close()
 
-	pbg.AddRelationFunc(func(ch chan []string) {
+	pbg.AddRelationFunc(func(ch chan []interface{}) error {
 		idx := 0
 
 		scanner := bufio.NewScanner(file)
@@ -45,17 +44,37 @@ func loadMemtrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{})
 				continue
 			}
 
-			ch <- []string{ parts[0], parts[1] + "-address", parts[2] }
-		}
+			pc, err := graph.ParseAddr(parts[0])
+
+			if err != nil {
+				continue
+			}
+
+			addr, err := graph.ParseAddr(parts[2])
 
-		if err := scanner.Err(); err != nil {
-			panic(err)
+			if err != nil {
+				continue
+			}
+
+			ch <- []interface{}{ pc, parts[1] + "-address", addr }
 		}
 
-		close(ch)
+		return scanner.Err()
 	})
+
+	return nil
 }
 
 func init() {
-	graph.RegisterProvider("memtrace", loadMemtrace, "elf")
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "memtrace",
+		Run: loadMemtrace,
+		Deps: []string{ "elf" },
+		RunScoped: true,
+		Options: []graph.PBGOption{
+			{ Name: "memTraceFile", Type: graph.OptionString, Description: "csv of pc,read|write,address accesses" },
+		},
+		Produces: []string{ "read-address", "write-address" },
+		Types: traceTypes("read-address", "write-address"),
+	})
 }
\ No newline at end of file
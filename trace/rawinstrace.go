@@ -2,7 +2,6 @@ package trace;
 
 import (
 	"fmt"
-	"log"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -12,12 +11,12 @@ import (
 	"path"
 )
 
-func loadRawInstrTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
+func loadRawInstrTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
 	cmdLine, ok := opt["cmdLine"].(string)
 
 	if !ok {
-		log.Printf("No instruction trace command found, skipping...\n")
-		return
+		pbg.Logger().Printf("No instruction trace command found, skipping...\n")
+		return nil
 	}
 
 	env := ""
@@ -34,7 +33,7 @@ func loadRawInstrTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interfac
 	}
 
 	if env == "" { 
-		panic("Failed to find DYNAMORIO_HOME in path")
+		return fmt.Errorf("failed to find DYNAMORIO_HOME in the environment")
 	}
 
 	// Create execution object
@@ -49,7 +48,7 @@ func loadRawInstrTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interfac
 		args = append(args, arg)
 	}
 
-	log.Printf("Executing instrace (%s %s)...\n", total_path, args)
+	pbg.Logger().Printf("Executing instrace (%s %s)...\n", total_path, args)
 
 	cmdObj := exec.Command(total_path, args...)
 
@@ -57,15 +56,16 @@ func loadRawInstrTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interfac
 	stdoutObj, err := cmdObj.StdoutPipe()
 
 	if err != nil {
-		panic(err)
-		return
+		return err
 	}
 
 
-	cmdObj.Start()
+	if err := cmdObj.Start(); err != nil {
+		return err
+	}
 
 	slurp, _ := ioutil.ReadAll(stdoutObj)
-	log.Printf("Output: %s\n", slurp)
+	pbg.Logger().Printf("Output: %s\n", slurp)
 
 	log_path := ""
 
@@ -80,22 +80,23 @@ func loadRawInstrTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interfac
 	}
 
 	if log_path == "" {
-		panic("Failed to find log path in rawinstrace result")
+		return fmt.Errorf("failed to find log path in rawinstrace result")
 	}
 
 	file, err := os.Open(log_path)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	defer file.Close()
 
 	count := 0
+	traceId := newTrace(pbg, "rawinstrtrace", cmdLine)
 
-	pbg.AddRelationFunc(func(ch chan []string) {
+	pbg.AddRelationFunc(func(ch chan []interface{}) error {
 		idx := 0
-		var last string
+		var last graph.Addr
 
 		scanner := bufio.NewScanner(file)
 		
@@ -115,31 +116,45 @@ func loadRawInstrTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interfac
 				continue
 			}
 
+			pc, err := graph.ParseAddr(parts[0])
+
+			if err != nil {
+				continue
+			}
+
 			if ( idx > 1 ) {
 				// Form step nodes
-				lastIndex := fmt.Sprintf("step-%d", count)
+				lastIndex := traceStepId(traceId, count)
 				count += 1
-				index := fmt.Sprintf("step-%d", count)
+				index := traceStepId(traceId, count)
 
 				// Add tuples into database
-				ch <- []string{ last, "next-address", parts[0] }
-				ch <- []string{ index, "step-address", last }
-				ch <- []string { lastIndex, "next-step", index }
+				ch <- []interface{}{ last, "next-address", pc }
+				ch <- []interface{}{ index, "step-address", last }
+				ch <- []interface{} { lastIndex, "next-step", index }
 			}
 
-			last = parts[0]
+			last = pc
 		}
 
-		if err := scanner.Err(); err != nil {
-			panic(err)
-		}
-
-		close(ch)
+		return scanner.Err()
 	})
 
-	log.Printf("Loaded %d instructions in trace\n", count)
+	pbg.Logger().Printf("Loaded %d instructions in trace\n", count)
+
+	return nil
 }
 
 func init() {
-	graph.RegisterProvider("rawinstrtrace", loadRawInstrTrace, "elf")
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "rawinstrtrace",
+		Run: loadRawInstrTrace,
+		Deps: []string{ "elf" },
+		RunScoped: true,
+		Options: []graph.PBGOption{
+			{ Name: "cmdLine", Type: graph.OptionString, Required: true, Description: "command to trace under DynamoRIO" },
+		},
+		Produces: []string{ graph.NamePredicate, "next-address", "step-address", "next-step" },
+		Types: traceTypes(graph.NamePredicate, "next-address", "step-address", "next-step"),
+	})
 }
\ No newline at end of file
@@ -2,7 +2,6 @@ package trace;
 
 import (
 	"fmt"
-	"log"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -10,14 +9,28 @@ import (
 	"pbg/graph"
 	"strings"
 	"path"
+
+	"github.com/cayleygraph/cayley/quad"
 )
 
-func loadRawInstrAllocTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
+// Parse an allocation size or count. The client prints them like addresses,
+// so they're read the same way and stored as numbers.
+func parseAmount(s string) (quad.Value, error) {
+	n, err := graph.ParseAddr(s)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+
+	return quad.Int(int64(n)), nil
+}
+
+func loadRawInstrAllocTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
 	cmdLine, ok := opt["cmdLine"].(string)
 
 	if !ok {
-		log.Printf("No instralloc trace command found, skipping...\n")
-		return
+		pbg.Logger().Printf("No instralloc trace command found, skipping...\n")
+		return nil
 	}
 
 	env := ""
@@ -34,7 +47,7 @@ func loadRawInstrAllocTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] int
 	}
 
 	if env == "" { 
-		panic("Failed to find DYNAMORIO_HOME in path")
+		return fmt.Errorf("failed to find DYNAMORIO_HOME in the environment")
 	}
 
 	// Create execution object
@@ -46,7 +59,7 @@ func loadRawInstrAllocTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] int
 		args = append(args, arg)
 	}
 
-	log.Printf("Executing client (%s %s)...\n", total_path, args)
+	pbg.Logger().Printf("Executing client (%s %s)...\n", total_path, args)
 
 	cmdObj := exec.Command(total_path, args...)
 
@@ -54,23 +67,25 @@ func loadRawInstrAllocTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] int
 	stderrObj, err := cmdObj.StderrPipe()
 
 	if err != nil {
-		panic(err)
-		return
+		return err
 	}
 
 	stdoutObj, err := cmdObj.StdoutPipe()
 
 	if err != nil {
-		panic(err)
-		return
+		return err
+	}
+
+	if err := cmdObj.Start(); err != nil {
+		return err
 	}
 
-	cmdObj.Start()
+	traceId := newTrace(pbg, "rawinstralloctrace", cmdLine)
 
-	pbg.AddRelationFunc(func(ch chan []string) {
+	pbg.AddRelationFunc(func(ch chan []interface{}) error {
 		scanner := bufio.NewScanner(stderrObj)
-		var lastPC string
-		lastStep := "step-1"
+		var lastPC graph.Addr
+		lastStep := traceStepId(traceId, 1)
 		idx := 0
 		count := 0
 
@@ -82,30 +97,53 @@ func loadRawInstrAllocTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] int
 
 				fmt.Sscanf(text, "free %s", &loc)
 
-				ch <- []string{ lastStep, "free-at", loc }
+				if addr, err := graph.ParseAddr(loc); err == nil {
+					ch <- []interface{}{ lastStep, "free-at", addr }
+				}
 			} else if len(text) >= 6 && text[:6] == "malloc" {
 				var amt, addr string
 
 				fmt.Sscanf(text, "malloc %s %s", &amt, &addr)
 
-				ch <- []string { lastStep, "malloc-amt", amt }
-				ch <- []string { lastStep, "malloc-ptr", addr }
+				if n, err := parseAmount(amt); err == nil {
+					ch <- []interface{} { lastStep, "malloc-amt", n }
+				}
+
+				if ptr, err := graph.ParseAddr(addr); err == nil {
+					ch <- []interface{} { lastStep, "malloc-ptr", ptr }
+				}
 			} else if len(text) >= 7 && text[:7] == "realloc" {
 				var amt, oldAddr, newAddr string
 
 				fmt.Sscanf(text, "realloc %s %s %s", &oldAddr, &amt, &newAddr)
 
-				ch <- []string { lastStep, "realloc-old-addr", oldAddr }
-				ch <- []string { lastStep, "realloc-amt", amt }
-				ch <- []string { lastStep, "realloc-new-addr", newAddr }
+				if ptr, err := graph.ParseAddr(oldAddr); err == nil {
+					ch <- []interface{} { lastStep, "realloc-old-addr", ptr }
+				}
+
+				if n, err := parseAmount(amt); err == nil {
+					ch <- []interface{} { lastStep, "realloc-amt", n }
+				}
+
+				if ptr, err := graph.ParseAddr(newAddr); err == nil {
+					ch <- []interface{} { lastStep, "realloc-new-addr", ptr }
+				}
 			} else if len(text) >= 6 && text[:6] == "calloc" {
 				var amt, cnt, addr string
 
 				fmt.Sscanf(text, "calloc %s %s %s", &amt, &cnt, &addr)
 
-				ch <- []string { lastStep, "calloc-amt", amt }
-				ch <- []string { lastStep, "calloc-cnt", cnt }
-				ch <- []string { lastStep, "calloc-addr", addr }
+				if n, err := parseAmount(amt); err == nil {
+					ch <- []interface{} { lastStep, "calloc-amt", n }
+				}
+
+				if n, err := parseAmount(cnt); err == nil {
+					ch <- []interface{} { lastStep, "calloc-cnt", n }
+				}
+
+				if ptr, err := graph.ParseAddr(addr); err == nil {
+					ch <- []interface{} { lastStep, "calloc-addr", ptr }
+				}
 			} else {
 				if idx == 0 || len(text) == 0 {
 					idx += 1
@@ -114,34 +152,56 @@ func loadRawInstrAllocTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] int
 
 				idx += 1
 
+				pc, err := graph.ParseAddr(text)
+
+				if err != nil {
+					continue
+				}
+
 				if ( idx > 1 ) {
 					// Form step nodes
 					count += 1
-					index := fmt.Sprintf("step-%d", count)
+					index := traceStepId(traceId, count)
 
 					// Add tuples into database
-					ch <- []string{ lastPC, "next-address", text }
-					ch <- []string{ index, "step-address", text }
-					ch <- []string{ lastStep, "next-step", index }
+					ch <- []interface{}{ lastPC, "next-address", pc }
+					ch <- []interface{}{ index, "step-address", pc }
+					ch <- []interface{}{ lastStep, "next-step", index }
 
 					lastStep = index
 				}
 
-				lastPC = text
+				lastPC = pc
 			}
 		}
 
-		if err := scanner.Err(); err != nil {
-			panic(err)
-		}
-
-		close(ch)
+		return scanner.Err()
 	})
  
 	slurp, _ := ioutil.ReadAll(stdoutObj)
-	log.Printf("Output: %s\n", slurp)
+	pbg.Logger().Printf("Output: %s\n", slurp)
+
+	return nil
 }
 
 func init() {
-	graph.RegisterProvider("rawinstralloctrace", loadRawInstrAllocTrace, "elf")
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "rawinstralloctrace",
+		Run: loadRawInstrAllocTrace,
+		Deps: []string{ "elf" },
+		RunScoped: true,
+		Options: []graph.PBGOption{
+			{ Name: "cmdLine", Type: graph.OptionString, Required: true, Description: "command to trace under DynamoRIO" },
+		},
+		Produces: []string{
+			graph.NamePredicate, "next-address", "step-address", "next-step",
+			"free-at", "malloc-amt", "malloc-ptr", "realloc-old-addr", "realloc-amt",
+			"realloc-new-addr", "calloc-amt", "calloc-cnt", "calloc-addr",
+		},
+		Types: traceTypes(
+			graph.NamePredicate, "next-address", "step-address", "next-step",
+			"free-at", "malloc-amt", "malloc-ptr", "realloc-old-addr", "realloc-amt",
+			"realloc-new-addr", "calloc-amt", "calloc-cnt", "calloc-addr",
+		),
+	})
 }
\ No newline at end of file
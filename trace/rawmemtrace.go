@@ -1,8 +1,8 @@
 package trace;
 
 import (
+	"fmt"
 	"compress/gzip"
-	"log"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -12,12 +12,12 @@ import (
 	"path"
 )
 
-func loadRawMemTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) {
+func loadRawMemTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{}) error {
 	cmdLine, ok := opt["cmdLine"].(string)
 
 	if !ok {
-		log.Printf("No memory trace command found, skipping...\n")
-		return
+		pbg.Logger().Printf("No memory trace command found, skipping...\n")
+		return nil
 	}
 
 	env := ""
@@ -34,7 +34,7 @@ func loadRawMemTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{
 	}
 
 	if env == "" { 
-		panic("Failed to find DYNAMORIO_HOME in path")
+		return fmt.Errorf("failed to find DYNAMORIO_HOME in the environment")
 	}
 
 	// Create execution object
@@ -46,7 +46,7 @@ func loadRawMemTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{
 		args = append(args, arg)
 	}
 
-	log.Printf("Executing drcachesim (%s %s)...\n", total_path, args)
+	pbg.Logger().Printf("Executing drcachesim (%s %s)...\n", total_path, args)
 
 
 	cmdObj := exec.Command(total_path, args...)
@@ -56,23 +56,23 @@ func loadRawMemTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{
 	stderrObj, err := cmdObj.StderrPipe()
 
 	if err != nil {
-		panic(err)
-		return
+		return err
 	}
 
 	stdoutObj, err := cmdObj.StdoutPipe()
 
 	if err != nil {
-		panic(err)
-		return
+		return err
 	}
 
 
-	cmdObj.Start()
+	if err := cmdObj.Start(); err != nil {
+		return err
+	}
 
 
 	// Input parsing similar to memtrace.py.
-	pbg.AddRelationFunc(func(ch chan []string) {
+	pbg.AddRelationFunc(func(ch chan []interface{}) error {
 		scanner := bufio.NewScanner(stderrObj)
 		
 		for scanner.Scan() {
@@ -84,28 +84,36 @@ func loadRawMemTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{
 
 			cmd := strings.Split(text[strings.Index(text, "@") + 1:], " ");
 
-			if len(cmd) < 2 || (cmd[1] != "read" && cmd[1] != "write") {
+			if len(cmd) < 3 || (cmd[1] != "read" && cmd[1] != "write") {
 				continue
 			}
 
 
-			ch <- []string{ cmd[0], cmd[1] + "-address", cmd[2] }
-		}
+			pc, err := graph.ParseAddr(cmd[0])
+
+			if err != nil {
+				continue
+			}
 
-		if err := scanner.Err(); err != nil {
-			panic(err)
+			addr, err := graph.ParseAddr(cmd[2])
+
+			if err != nil {
+				continue
+			}
+
+			ch <- []interface{}{ pc, cmd[1] + "-address", addr }
 		}
 
-		close(ch)
+		return scanner.Err()
 	})
  
 	slurp, _ := ioutil.ReadAll(stdoutObj)
-	log.Printf("Output: %s\n", slurp)
+	pbg.Logger().Printf("Output: %s\n", slurp)
 
 	file, err := os.Open("./.tmp_cache.gz")
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	defer file.Close()
@@ -113,10 +121,10 @@ func loadRawMemTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{
 	reader, err := gzip.NewReader(file)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	pbg.AddRelationFunc(func(ch chan []string) {
+	pbg.AddRelationFunc(func(ch chan []interface{}) error {
 		scanner := bufio.NewScanner(reader)
 
 		for scanner.Scan() {
@@ -127,17 +135,37 @@ func loadRawMemTrace(pbg *graph.ProgramBehaviorGraph, opt map[string] interface{
 				continue
 			}
 
-			ch <- []string{ elements[0], "miss-address", elements[1] }
-		}
+			pc, err := graph.ParseAddr(elements[0])
 
-		if err := scanner.Err(); err != nil {
-			panic(err)
+			if err != nil {
+				continue
+			}
+
+			addr, err := graph.ParseAddr(elements[1])
+
+			if err != nil {
+				continue
+			}
+
+			ch <- []interface{}{ pc, "miss-address", addr }
 		}
 
-		close(ch)
+		return scanner.Err()
 	})
+
+	return nil
 }
 
 func init() {
-	graph.RegisterProvider("rawmemtrace", loadRawMemTrace, "elf")
+	graph.RegisterProviderSpec(graph.PBGProviderSpec{
+		Name: "rawmemtrace",
+		Run: loadRawMemTrace,
+		Deps: []string{ "elf" },
+		RunScoped: true,
+		Options: []graph.PBGOption{
+			{ Name: "cmdLine", Type: graph.OptionString, Required: true, Description: "command to trace under drcachesim" },
+		},
+		Produces: []string{ "read-address", "write-address", "miss-address" },
+		Types: traceTypes("read-address", "write-address", "miss-address"),
+	})
 }
\ No newline at end of file
@@ -0,0 +1,60 @@
+package trace;
+
+import (
+	"strconv"
+	"pbg/graph"
+
+	"github.com/cayleygraph/cayley/quad"
+)
+
+// Create the node for a recorded execution. Steps of different providers and
+// command lines are kept apart by scoping them under it.
+func newTrace(pbg *graph.ProgramBehaviorGraph, provider string, cmdLine string) quad.IRI {
+	traceId := graph.TraceID(provider + " " + cmdLine)
+	pbg.AddName(traceId, cmdLine)
+
+	return traceId
+}
+
+// Identity of the n-th step of a trace
+func traceStepId(traceId quad.IRI, index int) quad.IRI {
+	return graph.ChildID(traceId, "step", strconv.Itoa(index))
+}
+
+var (
+	addressType = graph.PBGPredicateType{ Subject: graph.ValueNumber, Object: graph.ValueNumber }
+	stepValueType = graph.PBGPredicateType{ Subject: graph.ValueSymbol, Object: graph.ValueNumber }
+	stepType = graph.PBGPredicateType{ Subject: graph.ValueSymbol, Object: graph.ValueSymbol }
+)
+
+// Value kinds of the predicates traces produce. Pcs and accessed addresses
+// are numbers, steps are entities and allocation sizes are numbers too.
+var traceTypeTable = map[string] graph.PBGPredicateType {
+	graph.NamePredicate: stepType,
+	"next-address": addressType,
+	"read-address": addressType,
+	"write-address": addressType,
+	"miss-address": addressType,
+	"step-address": stepValueType,
+	"next-step": stepType,
+	"free-at": stepValueType,
+	"malloc-amt": stepValueType,
+	"malloc-ptr": stepValueType,
+	"realloc-old-addr": stepValueType,
+	"realloc-amt": stepValueType,
+	"realloc-new-addr": stepValueType,
+	"calloc-amt": stepValueType,
+	"calloc-cnt": stepValueType,
+	"calloc-addr": stepValueType,
+}
+
+// Declared types of some of the predicates above, for a provider spec
+func traceTypes(predicates ...string) map[string] graph.PBGPredicateType {
+	types := make(map[string] graph.PBGPredicateType)
+
+	for _, predicate := range predicates {
+		types[predicate] = traceTypeTable[predicate]
+	}
+
+	return types
+}